@@ -0,0 +1,378 @@
+package chess_engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AlphaBetaEngine is a classic negamax search with alpha-beta pruning and
+// iterative deepening. Unlike the BSEngine it looks at every move up to the
+// search depth, but it can prune lines that are provably worse than what we
+// have already found.
+type AlphaBetaEngine struct {
+	StartingPosition   *Game
+	Cancel             context.CancelFunc
+	Evaluators         Evaluators
+	SelDepth           int
+	TranspositionTable *TranspositionTable
+	TimeManager        *TimeManager
+	Limits             *SearchLimits
+	HistoryTable       *HistoryTable
+	// The number of best lines to report
+	MultiPV int
+
+	TotalNodes   int
+	CurrentDepth int
+	SelDepthSeen int
+	BestLine     []*Move
+	BestScore    Score
+	// The best lines from the last completed iteration, best first
+	Lines []*EvalResult
+
+	maxNodes  int
+	startTime time.Time
+	// Root moves that are skipped, because we already have a line for them
+	excludedRootMoves []*Move
+}
+
+func NewAlphaBetaEngine(depth int) *AlphaBetaEngine {
+	return &AlphaBetaEngine{
+		SelDepth:           depth,
+		MultiPV:            1,
+		TranspositionTable: NewTranspositionTable(DefaultHashSize),
+		HistoryTable:       NewHistoryTable(),
+	}
+}
+
+func (a *AlphaBetaEngine) GetPosition() *Game {
+	return a.StartingPosition
+}
+
+func (a *AlphaBetaEngine) SetPosition(fen *Game) {
+	a.StartingPosition = fen
+}
+
+func (a *AlphaBetaEngine) AddEvaluator(e Evaluator) {
+	a.Evaluators = append(a.Evaluators, e)
+}
+
+// Threads are not supported yet, so they are ignored.
+func (a *AlphaBetaEngine) SetOption(opt EngineOption, val OptionValue) error {
+	if opt == SELDEPTH {
+		a.SelDepth = val.Int
+	} else if opt == MULTIPV {
+		a.MultiPV = val.Int
+	} else if opt == HASH {
+		a.TranspositionTable = NewTranspositionTable(val.Int)
+	} else if opt == EVALUATORS {
+		evaluators, err := ParseEvaluators(val.String)
+		if err != nil {
+			return err
+		}
+		a.Evaluators = evaluators
+	}
+	return nil
+}
+
+// Forgets everything we've learned in the previous game.
+func (a *AlphaBetaEngine) NewGame() {
+	a.TranspositionTable.Clear()
+	a.HistoryTable.Clear()
+}
+
+func (a *AlphaBetaEngine) Start(output chan string, limits *SearchLimits) {
+	if limits == nil {
+		limits = NewSearchLimits()
+	}
+	timeManager := NewTimeManager(limits, a.StartingPosition.ToMove)
+	ctx, cancel := timeManager.Start(context.Background())
+	a.Cancel = cancel
+	a.TimeManager = timeManager
+	a.Limits = limits
+	go a.start(ctx, output, limits, timeManager)
+}
+
+func (a *AlphaBetaEngine) PonderHit() {
+	if a.TimeManager != nil {
+		a.TimeManager.PonderHit()
+	}
+}
+
+func (a *AlphaBetaEngine) Stop() {
+	if a.Cancel != nil {
+		a.Cancel()
+	}
+}
+
+func (a *AlphaBetaEngine) start(ctx context.Context, output chan string, limits *SearchLimits, timeManager *TimeManager) {
+	a.TotalNodes = 0
+	a.CurrentDepth = 0
+	a.SelDepthSeen = 0
+	a.BestLine = nil
+	a.BestScore = LowestScore
+	a.Lines = nil
+	a.maxNodes = limits.Nodes
+	a.startTime = time.Now()
+	a.TranspositionTable.NewSearch()
+	a.HistoryTable.NewSearch()
+	if err := limits.CheckSearchMoves(a.StartingPosition); err != nil {
+		output <- "info string error: " + err.Error()
+	}
+
+	maxDepth := limits.MaxDepth(a.SelDepth)
+	for depth := 1; depth <= maxDepth; depth++ {
+		if depth > 1 && !timeManager.CanStartIteration() {
+			break
+		}
+		// There's nothing to report if there are no moves to search.
+		lines, ok := a.searchRoot(ctx, depth)
+		if !ok || len(lines) == 0 {
+			break
+		}
+		a.CurrentDepth = depth
+		a.Lines = lines
+		a.BestScore = lines[0].Score
+		a.BestLine = lines[0].Line
+		a.outputInfo(output)
+		// No need to look any further if we've found a forced mate.
+		if a.BestScore.IsMateInNOrBetter(depth) || limits.IsMateFound(a.BestScore) {
+			break
+		}
+	}
+	timeManager.WaitForStop(ctx)
+	a.outputBestMove(output)
+}
+
+// Searches the starting position once for every line we want to report.
+// Every search skips the first moves of the lines we've already found, so
+// that we get the next best line, until we run out of root moves. Returns
+// false if the search was interrupted.
+func (a *AlphaBetaEngine) searchRoot(ctx context.Context, depth int) ([]*EvalResult, bool) {
+	defer func() {
+		a.excludedRootMoves = nil
+	}()
+	results := []*EvalResult{}
+	for len(results) == 0 || len(results) < a.MultiPV {
+		score, line, ok := a.negamax(ctx, a.StartingPosition, depth, 0, LowestScore+1, -(LowestScore + 1))
+		if !ok {
+			return nil, false
+		}
+		if len(line) == 0 {
+			// There are no (more) moves to look at, so there's nothing to
+			// report either.
+			break
+		}
+		results = append(results, NewEvalResult(line, score))
+		a.excludedRootMoves = append(a.excludedRootMoves, line[0])
+	}
+	return results, true
+}
+
+// Returns whether we should skip this move at the root, either because
+// we're not allowed to search it, or because we already have a line for it.
+func (a *AlphaBetaEngine) skipRootMove(move *Move) bool {
+	if !a.Limits.IsSearchMove(move) {
+		return true
+	}
+	for _, excluded := range a.excludedRootMoves {
+		if *excluded == *move {
+			return true
+		}
+	}
+	return false
+}
+
+// Negamax search with alpha-beta pruning. Scores are from the perspective of
+// the side to move. Returns false if the search was interrupted, in which
+// case the result should not be used.
+func (a *AlphaBetaEngine) negamax(ctx context.Context, game *Game, depth, ply int, alpha, beta Score) (Score, []*Move, bool) {
+	if a.shouldStop(ctx) {
+		return 0, nil, false
+	}
+	a.TotalNodes++
+	if ply > a.SelDepthSeen {
+		a.SelDepthSeen = ply
+	}
+
+	if ply > 0 && game.IsDraw() {
+		return Draw, nil, true
+	}
+	if game.IsMate() {
+		return Score(OpponentMate + ply), nil, true
+	}
+	if depth == 0 {
+		score, nodes := a.Evaluators.Quiescence(game, alpha, beta, ply)
+		a.TotalNodes += nodes - 1
+		return score, nil, true
+	}
+
+	origAlpha := alpha
+	var ttMove *Move
+	if entry := a.TranspositionTable.Probe(game.Hash); entry != nil {
+		ttMove = entry.BestMove
+		if ply > 0 && int(entry.Depth) >= depth {
+			score := entry.GetScore(ply)
+			if entry.Bound == ExactBound {
+				return score, a.transpositionTableLine(game, depth), true
+			} else if entry.Bound == LowerBound && score > alpha {
+				alpha = score
+			} else if entry.Bound == UpperBound && score < beta {
+				beta = score
+			}
+			if alpha >= beta {
+				return score, a.transpositionTableLine(game, depth), true
+			}
+		}
+	}
+
+	bestScore := LowestScore
+	var bestLine []*Move
+	for _, next := range orderNextGames(game, ttMove, a.HistoryTable, ply) {
+		if ply == 0 && a.skipRootMove(next.Line[len(next.Line)-1]) {
+			continue
+		}
+		score, line, ok := a.negamax(ctx, next, depth-1, ply+1, -beta, -alpha)
+		if !ok {
+			return 0, nil, false
+		}
+		score = score * -1
+		if score > bestScore {
+			bestScore = score
+			bestLine = append([]*Move{next.Line[len(next.Line)-1]}, line...)
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			move := next.Line[len(next.Line)-1]
+			if !game.IsCapture(move) && move.Promote == NoPiece {
+				a.HistoryTable.AddCutoff(move, ply, depth)
+			}
+			break
+		}
+	}
+	if ply > 0 {
+		// Free up the memory of the moves we've looked at
+		game.nextGames = nil
+	}
+
+	bound := ExactBound
+	if bestScore <= origAlpha {
+		bound = UpperBound
+	} else if bestScore >= beta {
+		bound = LowerBound
+	}
+	var bestMove *Move
+	if len(bestLine) > 0 {
+		bestMove = bestLine[0]
+	}
+	// When we're skipping root moves, the result isn't the real score of
+	// the position, so we shouldn't remember it.
+	if ply > 0 || (len(a.excludedRootMoves) == 0 && len(a.Limits.SearchMoves) == 0) {
+		a.TranspositionTable.Store(game.Hash, ply, depth, bestScore, bound, bestMove)
+	}
+	return bestScore, bestLine, true
+}
+
+// Returns the line we get by following the best moves in the transposition
+// table from @game, for at most @depth moves. We don't search any further
+// after a transposition table cutoff, so this is what we report as the rest
+// of the principal variation.
+func (a *AlphaBetaEngine) transpositionTableLine(game *Game, depth int) []*Move {
+	line := []*Move{}
+	for len(line) < depth {
+		entry := a.TranspositionTable.Probe(game.Hash)
+		if entry == nil || entry.BestMove == nil {
+			break
+		}
+		move := game.FindValidMove(entry.BestMove)
+		if move == nil {
+			break
+		}
+		line = append(line, move)
+		game = game.ApplyMove(move)
+	}
+	return line
+}
+
+func (a *AlphaBetaEngine) shouldStop(ctx context.Context) bool {
+	if a.maxNodes > 0 && a.TotalNodes >= a.maxNodes {
+		return true
+	}
+	return ctx.Err() != nil
+}
+
+// Orders the next games so that the move from the transposition table comes
+// first, then captures and promotions (most valuable victim first), then the
+// killer moves for this ply and then all the other moves, ordered by their
+// history score. This makes it a lot more likely that we can prune.
+func orderNextGames(game *Game, ttMove *Move, history *HistoryTable, ply int) []*Game {
+	nextGames := game.NextGames()
+	result := make([]*Game, len(nextGames))
+	copy(result, nextGames)
+	priority := func(next *Game) int {
+		move := next.Line[len(next.Line)-1]
+		if ttMove != nil && *move == *ttMove {
+			return 1 << 30
+		}
+		p := 0
+		if game.Board[move.To] != NoPiece {
+			p += 10 + int(game.Board[move.To].ToNormalizedPiece())
+		}
+		if move.Promote != NoPiece {
+			p += 10 + int(move.Promote.ToNormalizedPiece())
+		}
+		if p > 0 {
+			return 1<<24 + p
+		}
+		if history == nil {
+			return 0
+		}
+		if rank := history.KillerRank(move, ply); rank > 0 {
+			return 1<<20 + rank
+		}
+		return history.GetScore(move)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return priority(result[i]) > priority(result[j])
+	})
+	return result
+}
+
+func (a *AlphaBetaEngine) outputInfo(output chan string) {
+	elapsed := time.Since(a.startTime)
+	for i, result := range a.Lines {
+		multiPV := ""
+		if a.MultiPV > 1 {
+			multiPV = fmt.Sprintf(" multipv %d", i+1)
+		}
+		output <- fmt.Sprintf("info depth %d seldepth %d%s score %s nodes %d nps %d time %d pv %s",
+			a.CurrentDepth,
+			a.SelDepthSeen,
+			multiPV,
+			result.Score.ToUCI(),
+			a.TotalNodes,
+			nodesPerSecond(a.TotalNodes, elapsed),
+			elapsed.Milliseconds(),
+			Line(result.Line).String())
+	}
+}
+
+func (a *AlphaBetaEngine) outputBestMove(output chan string) {
+	if len(a.BestLine) > 1 {
+		output <- fmt.Sprintf("bestmove %s ponder %s", a.BestLine[0].String(), a.BestLine[1].String())
+		return
+	} else if len(a.BestLine) > 0 {
+		output <- fmt.Sprintf("bestmove %s", a.BestLine[0].String())
+		return
+	}
+	// We didn't complete a single iteration, so any valid move will do.
+	moves := a.Limits.FilterMoves(a.StartingPosition.ValidMoves())
+	if len(moves) == 0 {
+		output <- "bestmove (none)"
+		return
+	}
+	output <- fmt.Sprintf("bestmove %s", moves[0].String())
+}
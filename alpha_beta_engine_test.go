@@ -0,0 +1,112 @@
+package chess_engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AlphaBetaEngine_Can_Find_Mate(t *testing.T) {
+	cases := []struct {
+		FEN      string
+		Depth    int
+		Expected string
+	}{
+		{"8/8/8/qn6/kn6/1n6/1KP5/8 w - - 0 0", 1, "c2b3"},
+		{"7r/p3ppk1/3p4/2p1P1Kp/2P2Q2/3Pb1Pq/PP5P/R6R b - - 2 2", 1, "h3g4"},
+		{"r1bq2r1/b4pk1/p1pp1p2/1p2pP2/1P2P1PB/3P4/1PPQ2P1/R3K2R w - - 0 0", 3, "d2h6"},
+	}
+	for _, c := range cases {
+		fen, err := ParseFEN(c.FEN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := NewAlphaBetaEngine(c.Depth)
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		bestmove := getBestMove(unit, 5*time.Second)
+		if bestmove != c.Expected {
+			t.Errorf("Expecting %s in %s, got %s", c.Expected, c.FEN, bestmove)
+		}
+		if !unit.BestScore.IsMateInNOrBetter(c.Depth) {
+			t.Errorf("Expecting a mate score in %s, got %d", c.FEN, unit.BestScore)
+		}
+	}
+}
+
+func Test_AlphaBetaEngine_should_take_free_material(t *testing.T) {
+	fen, err := ParseFEN("rnbqkb1r/pppppppp/8/8/3PP1n1/8/PPP2PPP/RNBQKBNR w KQkq - 1 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewAlphaBetaEngine(2)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, 5*time.Second)
+	if bestmove != "d1g4" {
+		t.Errorf("Expecting d1g4, got %s", bestmove)
+	}
+}
+
+func Test_AlphaBetaEngine_shouldnt_hang_the_queen(t *testing.T) {
+	// Taking the pawn on d7 loses the queen to the king, which a search
+	// that looks at every reply should spot.
+	fen, err := ParseFEN("4k3/3p4/8/8/8/8/3Q4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewAlphaBetaEngine(2)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, 5*time.Second)
+	if bestmove == "d2d7" {
+		t.Errorf("Not expecting the queen to be given away")
+	}
+}
+
+func Test_AlphaBetaEngine_NewGame_clears_history(t *testing.T) {
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewAlphaBetaEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	searchWithLimits(unit, NewSearchLimits())
+	if unit.HistoryTable.History == [64][64]int{} {
+		t.Errorf("Expecting the search to have recorded some history")
+	}
+	unit.NewGame()
+	if unit.HistoryTable.History != [64][64]int{} || unit.TranspositionTable.Probe(fen.Hash) != nil {
+		t.Errorf("Expecting a new game to clear the tables")
+	}
+}
+
+func Test_AlphaBetaEngine_transposition_table_hits_keep_the_pv(t *testing.T) {
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewAlphaBetaEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	searchWithLimits(unit, NewSearchLimits())
+	// The second search finds the replies to every root move in the
+	// transposition table, but should still report the whole line.
+	output := make(chan string, 1000)
+	unit.Start(output, NewSearchLimits())
+	for line := range output {
+		if strings.HasPrefix(line, "info depth 3 ") {
+			pv := strings.Fields(line[strings.Index(line, " pv ")+4:])
+			if len(pv) != 3 {
+				t.Errorf("Expecting a pv of 3 moves, got %s", line)
+			}
+		}
+		if strings.HasPrefix(line, "bestmove ") {
+			if !strings.Contains(line, " ponder ") {
+				t.Errorf("Expecting a ponder move, got %s", line)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package chess_engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CECP drives an Engine using version 2 of the Chess Engine Communication
+// Protocol, which is what XBoard and WinBoard speak. Unlike with UCI we keep
+// track of the game ourselves: the GUI only sends us the moves, and we
+// decide when it's our turn to move.
+type CECP struct {
+	Name   string
+	Engine Engine
+	// Where we write our responses to the GUI; stdout by default.
+	Output io.Writer
+
+	position *Game
+	// The position the game started from, at new or setboard, so that we
+	// can count the moves that have been played in the time control.
+	startingPosition *Game
+	// In force mode we only keep track of the moves that are played.
+	force       bool
+	engineColor Color
+	// Whether to send thinking output
+	post      bool
+	searching bool
+
+	// The time control as set by level, st and sd
+	movesPerSession int
+	baseTime        time.Duration
+	increment       time.Duration
+	moveTime        time.Duration
+	depth           int
+	// The clocks as set by time and otim
+	engineTime   time.Duration
+	opponentTime time.Duration
+}
+
+func NewCECP(engineName string, engine Engine) *CECP {
+	cecp := &CECP{
+		Name:   engineName,
+		Engine: engine,
+		Output: os.Stdout,
+	}
+	cecp.newGame()
+	return cecp
+}
+
+func (c *CECP) Start(reader *bufio.Reader) {
+	input := make(chan string)
+	engineOutput := make(chan string, 50)
+	go readLines(reader, input)
+
+	for {
+		select {
+		case cmdLine, ok := <-input:
+			if !ok {
+				// The GUI has gone away
+				c.stopSearch()
+				return
+			}
+			if cmdLine == "" {
+				continue
+			}
+			cmd, err := ParseCECPCommand(cmdLine)
+			if err != nil {
+				c.outputError(err, cmdLine)
+				continue
+			}
+			if cmd.Name == "quit" {
+				c.stopSearch()
+				return
+			}
+			if err := c.execute(cmd, engineOutput); err != nil {
+				c.outputError(err, cmdLine)
+			}
+		case out := <-engineOutput:
+			c.handleEngineOutput(out)
+		}
+	}
+}
+
+func (c *CECP) execute(cmd *CECPCommand, engineOutput chan string) error {
+	switch cmd.Name {
+	case "protover":
+		c.output(fmt.Sprintf(`feature myname="%s" usermove=1 setboard=1 ping=1 sigint=0 sigterm=0 colors=0 analyze=0 done=1`, c.Name))
+	case "new":
+		c.abortSearch(engineOutput)
+		c.newGame()
+	case "force":
+		c.abortSearch(engineOutput)
+		c.force = true
+	case "go":
+		if c.searching {
+			return fmt.Errorf("Already searching")
+		}
+		c.force = false
+		c.engineColor = c.position.ToMove
+		c.startSearch(engineOutput)
+	case "usermove":
+		if c.searching {
+			return fmt.Errorf("Can't make a move while searching")
+		}
+		move := c.position.FindValidMove(cmd.Move)
+		if move == nil {
+			c.output("Illegal move: " + cmd.Move.String())
+			return nil
+		}
+		c.playMove(move)
+		if !c.force && c.position.ToMove == c.engineColor {
+			c.startSearch(engineOutput)
+		}
+	case "?":
+		// Move now
+		c.stopSearch()
+	case "ping":
+		// We handle the commands in order, so everything before the ping
+		// has been done by now.
+		c.output(fmt.Sprintf("pong %d", cmd.Number))
+	case "level":
+		c.movesPerSession = cmd.MovesPerSession
+		c.baseTime = cmd.BaseTime
+		c.increment = cmd.Increment
+		c.moveTime = 0
+		c.engineTime, c.opponentTime = c.baseTime, c.baseTime
+	case "st":
+		c.moveTime = cmd.Time
+	case "sd":
+		c.depth = cmd.Number
+	case "time":
+		c.engineTime = cmd.Time
+	case "otim":
+		c.opponentTime = cmd.Time
+	case "undo", "remove":
+		plies := 1
+		if cmd.Name == "remove" {
+			plies = 2
+		}
+		position := c.position
+		for i := 0; i < plies; i++ {
+			if position.Parent == nil {
+				return fmt.Errorf("No move to take back")
+			}
+			position = position.Parent
+		}
+		c.abortSearch(engineOutput)
+		c.position = position
+	case "result":
+		c.abortSearch(engineOutput)
+		c.force = true
+	case "setboard":
+		c.abortSearch(engineOutput)
+		c.position = cmd.Position
+		c.startingPosition = cmd.Position
+	case "post":
+		c.post = true
+	case "nopost":
+		c.post = false
+	}
+	return nil
+}
+
+// Resets the board and the clocks. We play black, unless the GUI tells us
+// otherwise with go.
+func (c *CECP) newGame() {
+	game, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		panic(err)
+	}
+	c.position = game
+	c.startingPosition = game
+	c.force = false
+	c.engineColor = Black
+	c.depth = 0
+	c.engineTime, c.opponentTime = c.baseTime, c.baseTime
+	c.Engine.NewGame()
+}
+
+// Plays a move on our board. The new position has the previous ones as its
+// Parents, so that we can take moves back and detect repetitions.
+func (c *CECP) playMove(move *Move) {
+	c.position = c.position.ApplyMove(move)
+	c.position.Line = []*Move{}
+}
+
+func (c *CECP) startSearch(engineOutput chan string) {
+	if c.outputResult() {
+		return
+	}
+	c.searching = true
+	c.Engine.SetPosition(c.position)
+	c.Engine.Start(engineOutput, c.searchLimits())
+}
+
+// Translates the time control into limits for the engine.
+func (c *CECP) searchLimits() *SearchLimits {
+	limits := NewSearchLimits()
+	limits.Depth = c.depth
+	if c.moveTime > 0 {
+		limits.MoveTime = c.moveTime
+		return limits
+	}
+	if c.engineTime <= 0 {
+		return limits
+	}
+	if c.engineColor == White {
+		limits.WhiteTime, limits.BlackTime = c.engineTime, c.opponentTime
+	} else {
+		limits.WhiteTime, limits.BlackTime = c.opponentTime, c.engineTime
+	}
+	limits.WhiteIncrement, limits.BlackIncrement = c.increment, c.increment
+	if c.movesPerSession > 0 {
+		limits.MovesToGo = c.movesPerSession - c.movesPlayed()%c.movesPerSession
+	}
+	return limits
+}
+
+// Returns the number of moves we've played since the start of the game.
+func (c *CECP) movesPlayed() int {
+	moves := c.position.Fullmove - c.startingPosition.Fullmove
+	if c.engineColor == White && c.startingPosition.ToMove == Black {
+		// Black moved first, so the move number went up before our first
+		// move.
+		moves--
+	}
+	return moves
+}
+
+// Tells the engine to stop searching. It will still send its best move.
+func (c *CECP) stopSearch() {
+	if c.searching {
+		c.Engine.Stop()
+	}
+}
+
+// Stops the search and waits for it to finish, throwing away its result.
+func (c *CECP) abortSearch(engineOutput chan string) {
+	if !c.searching {
+		return
+	}
+	c.Engine.Stop()
+	for out := range engineOutput {
+		if strings.HasPrefix(out, "bestmove") {
+			break
+		}
+	}
+	c.searching = false
+}
+
+func (c *CECP) handleEngineOutput(out string) {
+	if !strings.HasPrefix(out, "bestmove") {
+		if thinking, ok := cecpThinkingOutput(out); ok && c.post {
+			c.output(thinking)
+		}
+		return
+	}
+	c.searching = false
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return
+	}
+	move, err := ParseMove(fields[1])
+	if err != nil {
+		// bestmove (none): the game is already over.
+		return
+	}
+	valid := c.position.FindValidMove(move)
+	if valid == nil {
+		c.output(fmt.Sprintf("Error (engine played an invalid move): %s", fields[1]))
+		return
+	}
+	c.playMove(valid)
+	c.output("move " + valid.String())
+	c.outputResult()
+}
+
+// Sends the result to the GUI if the game is over. Returns whether it was.
+func (c *CECP) outputResult() bool {
+	result := cecpResult(c.position)
+	if result == "" {
+		return false
+	}
+	c.output(result)
+	return true
+}
+
+// Returns the result of the game in the format the GUI expects, e.g.
+// "1-0 {White mates}", or an empty string if the game isn't over yet.
+func cecpResult(game *Game) string {
+	if game.IsMate() {
+		if game.ToMove == Black {
+			return "1-0 {White mates}"
+		}
+		return "0-1 {Black mates}"
+	}
+	if game.HalfmoveClock >= 100 {
+		return "1/2-1/2 {Fifty move rule}"
+	} else if game.IsThreefoldRepetition() {
+		return "1/2-1/2 {Draw by repetition}"
+	} else if game.IsInsufficientMaterial() {
+		return "1/2-1/2 {Insufficient material}"
+	} else if len(game.ValidMoves()) == 0 {
+		return "1/2-1/2 {Stalemate}"
+	}
+	return ""
+}
+
+// Converts an info line from the engine, e.g. "info depth 3 score cp 25
+// nodes 1000 time 50 pv e2e4 e7e5", into CECP thinking output: "ply score
+// time nodes pv", with the time in centiseconds. Mate in N is reported as
+// 100000+N and being mated in N as -100000-N.
+func cecpThinkingOutput(info string) (string, bool) {
+	fields := strings.Fields(info)
+	if len(fields) == 0 || fields[0] != "info" {
+		return "", false
+	}
+	intArg := func(i int) int {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.Atoi(fields[i])
+		return n
+	}
+	depth, score, centiseconds, nodes := 0, 0, 0, 0
+	pv := []string{}
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "string":
+			return "", false
+		case "depth":
+			depth = intArg(i + 1)
+			i++
+		case "score":
+			if i+1 < len(fields) && fields[i+1] == "mate" {
+				score = intArg(i + 2)
+				if score > 0 {
+					score += 100000
+				} else {
+					score -= 100000
+				}
+			} else {
+				score = intArg(i + 2)
+			}
+			i += 2
+		case "time":
+			centiseconds = intArg(i+1) / 10
+			i++
+		case "nodes":
+			nodes = intArg(i + 1)
+			i++
+		case "pv":
+			pv = fields[i+1:]
+			i = len(fields)
+		}
+	}
+	if depth == 0 || len(pv) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%d %d %d %d %s", depth, score, centiseconds, nodes, strings.Join(pv, " ")), true
+}
+
+func (c *CECP) output(line string) {
+	fmt.Fprintln(c.Output, line)
+}
+
+// Reports an error to the GUI in the format the protocol prescribes.
+func (c *CECP) outputError(err error, cmdLine string) {
+	c.output(fmt.Sprintf("Error (%s): %s", err.Error(), cmdLine))
+}
@@ -0,0 +1,159 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A command from an XBoard/WinBoard compatible GUI (CECP, the Chess Engine
+// Communication Protocol) that has been parsed and validated.
+type CECPCommand struct {
+	Name string
+
+	// The argument to ping, protover and sd
+	Number int
+	// The move for usermove
+	Move *Move
+	// The position for setboard
+	Position *Game
+	// The argument to time, otim and st
+	Time time.Duration
+	// The arguments to level
+	MovesPerSession int
+	BaseTime        time.Duration
+	Increment       time.Duration
+}
+
+var cecpCommands = map[string]bool{
+	"xboard":   true,
+	"protover": true,
+	"accepted": true,
+	"rejected": true,
+	"new":      true,
+	"quit":     true,
+	"force":    true,
+	"go":       true,
+	"usermove": true,
+	"?":        true,
+	"ping":     true,
+	"level":    true,
+	"st":       true,
+	"sd":       true,
+	"time":     true,
+	"otim":     true,
+	"undo":     true,
+	"remove":   true,
+	"result":   true,
+	"setboard": true,
+	"post":     true,
+	"nopost":   true,
+	"hard":     true,
+	"easy":     true,
+	"random":   true,
+	"computer": true,
+	"name":     true,
+	"draw":     true,
+}
+
+// Parses a line sent by the GUI. Older GUIs send moves without the usermove
+// prefix, so a line that isn't a known command, but is a move, is treated
+// as a usermove.
+func ParseCECPCommand(line string) (*CECPCommand, error) {
+	parts := strings.Fields(line)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("Empty command")
+	}
+	if !cecpCommands[parts[0]] {
+		if _, err := ParseMove(parts[0]); err != nil || len(parts) > 1 {
+			return nil, fmt.Errorf("Unknown command: %s", parts[0])
+		}
+		parts = append([]string{"usermove"}, parts...)
+	}
+	cmd := &CECPCommand{Name: parts[0]}
+	args := parts[1:]
+	switch cmd.Name {
+	case "protover", "ping", "sd":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Missing argument for %s", cmd.Name)
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Invalid argument for %s: %s", cmd.Name, args[0])
+		}
+		cmd.Number = n
+	case "usermove":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Missing move")
+		}
+		move, err := ParseMove(args[0])
+		if err != nil {
+			return nil, err
+		}
+		cmd.Move = move
+	case "setboard":
+		game, err := ParseFEN(strings.Join(args, " "))
+		if err != nil {
+			return nil, err
+		}
+		cmd.Position = game
+	case "time", "otim":
+		// In centiseconds
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Missing time for %s", cmd.Name)
+		}
+		cs, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time for %s: %s", cmd.Name, args[0])
+		}
+		cmd.Time = time.Duration(cs) * 10 * time.Millisecond
+	case "st":
+		// In seconds
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Missing time for st")
+		}
+		seconds, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("Invalid time for st: %s", args[0])
+		}
+		cmd.Time = time.Duration(seconds * float64(time.Second))
+	case "level":
+		if err := parseLevel(cmd, args); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
+}
+
+// Parses the arguments to level, e.g. "40 5 0" (40 moves in 5 minutes) or
+// "0 2:30 1" (the whole game in 2.5 minutes with a one second increment).
+func parseLevel(cmd *CECPCommand, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("Expecting level MPS BASE INC")
+	}
+	mps, err := strconv.Atoi(args[0])
+	if err != nil || mps < 0 {
+		return fmt.Errorf("Invalid moves per session: %s", args[0])
+	}
+	minutes, seconds := args[1], "0"
+	if i := strings.Index(args[1], ":"); i >= 0 {
+		minutes, seconds = args[1][:i], args[1][i+1:]
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 {
+		return fmt.Errorf("Invalid base time: %s", args[1])
+	}
+	s, err := strconv.Atoi(seconds)
+	if err != nil || s < 0 {
+		return fmt.Errorf("Invalid base time: %s", args[1])
+	}
+	inc, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || inc < 0 {
+		return fmt.Errorf("Invalid increment: %s", args[2])
+	}
+	cmd.MovesPerSession = mps
+	cmd.BaseTime = time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	cmd.Increment = time.Duration(inc * float64(time.Second))
+	return nil
+}
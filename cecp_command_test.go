@@ -0,0 +1,95 @@
+package chess_engine
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseCECPCommand(t *testing.T) {
+	cmd, err := ParseCECPCommand("usermove e7e8q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "usermove" || cmd.Move.String() != "e7e8q" {
+		t.Errorf("Unexpected command %v", cmd)
+	}
+	cmd, err = ParseCECPCommand("e2e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "usermove" || cmd.Move.String() != "e2e4" {
+		t.Errorf("Expecting a usermove, got %v", cmd)
+	}
+	cmd, err = ParseCECPCommand("time 1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Time != 12340*time.Millisecond {
+		t.Errorf("Expecting 12.34s, got %s", cmd.Time)
+	}
+	cmd, err = ParseCECPCommand("st 0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Time != 500*time.Millisecond {
+		t.Errorf("Expecting 0.5s, got %s", cmd.Time)
+	}
+	cmd, err = ParseCECPCommand("setboard 8/4P3/8/8/8/8/8/k6K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Position == nil || cmd.Position.FENString() != "8/4P3/8/8/8/8/8/k6K w - - 0 1" {
+		t.Errorf("Unexpected position %v", cmd.Position)
+	}
+	cmd, err = ParseCECPCommand("ping 42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Number != 42 {
+		t.Errorf("Expecting 42, got %d", cmd.Number)
+	}
+}
+
+func Test_ParseCECPCommand_level(t *testing.T) {
+	cases := []struct {
+		args      string
+		mps       int
+		base, inc time.Duration
+	}{
+		{"level 40 5 0", 40, 5 * time.Minute, 0},
+		{"level 0 2:30 1", 0, 150 * time.Second, time.Second},
+		{"level 0 0:15 0.5", 0, 15 * time.Second, 500 * time.Millisecond},
+	}
+	for _, testCase := range cases {
+		cmd, err := ParseCECPCommand(testCase.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd.MovesPerSession != testCase.mps || cmd.BaseTime != testCase.base || cmd.Increment != testCase.inc {
+			t.Errorf("Unexpected time control for '%s': %d %s %s", testCase.args, cmd.MovesPerSession, cmd.BaseTime, cmd.Increment)
+		}
+	}
+}
+
+func Test_ParseCECPCommand_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"unknown command",
+		"e2e4 e7e5",
+		"usermove",
+		"usermove e9e4",
+		"ping",
+		"sd abc",
+		"time",
+		"st 0",
+		"level 40 5",
+		"level 40 a:00 0",
+		"level 40 5 -1",
+		"setboard invalid",
+	}
+	for _, testCase := range cases {
+		if _, err := ParseCECPCommand(testCase); err == nil {
+			t.Errorf("Expecting an error for '%s'", testCase)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package chess_engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A CECP loop running in the background that we can talk to like a GUI
+// would. The output isn't buffered, so every line the engine sends needs to
+// be read before sending the next command.
+type cecpSession struct {
+	unit   *CECP
+	input  *io.PipeWriter
+	output *bufio.Scanner
+}
+
+func startCECP(engine Engine) *cecpSession {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	unit := NewCECP("test", engine)
+	unit.Output = outWriter
+	go func() {
+		unit.Start(bufio.NewReader(inReader))
+		outWriter.Close()
+	}()
+	return &cecpSession{unit, inWriter, bufio.NewScanner(outReader)}
+}
+
+func (s *cecpSession) send(lines ...string) {
+	for _, line := range lines {
+		fmt.Fprintln(s.input, line)
+	}
+}
+
+func (s *cecpSession) expect(t *testing.T, prefix string) string {
+	t.Helper()
+	if !s.output.Scan() {
+		t.Fatalf("Expecting '%s', but the output ended", prefix)
+	}
+	line := s.output.Text()
+	if !strings.HasPrefix(line, prefix) {
+		t.Fatalf("Expecting '%s', got '%s'", prefix, line)
+	}
+	return line
+}
+
+func (s *cecpSession) close() {
+	s.input.Close()
+}
+
+func Test_CECP_plays_a_game(t *testing.T) {
+	engine := NewAlphaBetaEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	session := startCECP(engine)
+	defer session.close()
+
+	session.send("xboard", "protover 2")
+	if feature := session.expect(t, "feature"); !strings.Contains(feature, "usermove=1") {
+		t.Errorf("Expecting usermove=1 in '%s'", feature)
+	}
+	session.send("new", "usermove e2e4")
+	reply := strings.TrimPrefix(session.expect(t, "move "), "move ")
+	start, _ := ParseFEN(StartingPositionFEN)
+	afterE4, _ := start.ApplyMoves([]string{"e2e4"})
+	move, err := ParseMove(reply)
+	if err != nil || afterE4.FindValidMove(move) == nil {
+		t.Fatalf("Expecting a valid move for black, got %s", reply)
+	}
+
+	session.send("usermove e2e5")
+	session.expect(t, "Illegal move: e2e5")
+
+	// In force mode we don't reply to moves
+	session.send("force", "usermove d2d4", "ping 1")
+	session.expect(t, "pong 1")
+	session.send("remove", "ping 2")
+	session.expect(t, "pong 2")
+	if session.unit.position.FENString() != afterE4.FENString() {
+		t.Errorf("Expecting %s after remove, got %s", afterE4.FENString(), session.unit.position.FENString())
+	}
+	session.send("undo", "undo")
+	session.expect(t, "Error (No move to take back): undo")
+}
+
+func Test_CECP_reports_the_result(t *testing.T) {
+	engine := NewAlphaBetaEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	session := startCECP(engine)
+	defer session.close()
+
+	session.send("new", "setboard 7k/5Q2/6K1/8/8/8/8/8 w - - 0 1", "sd 2", "go")
+	move := session.expect(t, "move ")
+	if move != "move f7g7" && move != "move f7f8" {
+		t.Errorf("Expecting a mate, got '%s'", move)
+	}
+	session.send("ping 1")
+	session.expect(t, "1-0 {White mates}")
+	session.expect(t, "pong 1")
+}
+
+func Test_CECP_new_aborts_the_search(t *testing.T) {
+	engine := NewBSEngine(100)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	session := startCECP(engine)
+	defer session.close()
+
+	session.send("new", "go", "new", "ping 1")
+	session.expect(t, "pong 1")
+	if !session.unit.position.IsSamePosition(mustParseFEN(StartingPositionFEN)) {
+		t.Errorf("Expecting the starting position, got %s", session.unit.position.FENString())
+	}
+}
+
+func Test_CECP_searchLimits(t *testing.T) {
+	unit := NewCECP("test", NewRandomEngine())
+	cmd, _ := ParseCECPCommand("level 40 5 2")
+	unit.execute(cmd, nil)
+	cmd, _ = ParseCECPCommand("otim 12000")
+	unit.execute(cmd, nil)
+	unit.position = mustParseFEN("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	unit.engineColor = White
+	limits := unit.searchLimits()
+	if limits.WhiteTime != 5*time.Minute || limits.BlackTime != 2*time.Minute {
+		t.Errorf("Unexpected clocks %s %s", limits.WhiteTime, limits.BlackTime)
+	}
+	if limits.WhiteIncrement != 2*time.Second || limits.MovesToGo != 39 {
+		t.Errorf("Unexpected increment %s and moves to go %d", limits.WhiteIncrement, limits.MovesToGo)
+	}
+	cmd, _ = ParseCECPCommand("st 3")
+	unit.execute(cmd, nil)
+	limits = unit.searchLimits()
+	if limits.MoveTime != 3*time.Second || limits.WhiteTime != 0 {
+		t.Errorf("Expecting a move time of 3s, got %s", limits.MoveTime)
+	}
+}
+
+func Test_CECP_searchLimits_counts_moves_from_setboard(t *testing.T) {
+	cases := []struct {
+		fen         string
+		engineColor Color
+		moves       []string
+		movesToGo   int
+	}{
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 30", White, nil, 40},
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 30", White, []string{"f1c4", "g8f6"}, 39},
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 30", Black, []string{"f1c4"}, 40},
+		{"rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 30", White, []string{"b8c6"}, 40},
+		{"rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 30", Black, []string{"b8c6", "f1c4"}, 39},
+	}
+	for _, testCase := range cases {
+		unit := NewCECP("test", NewRandomEngine())
+		for _, line := range []string{"level 40 5 0", "setboard " + testCase.fen} {
+			cmd, err := ParseCECPCommand(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unit.execute(cmd, nil)
+		}
+		for _, move := range testCase.moves {
+			unit.playMove(unit.position.FindValidMove(MustParseMove(move)))
+		}
+		unit.engineColor = testCase.engineColor
+		if limits := unit.searchLimits(); limits.MovesToGo != testCase.movesToGo {
+			t.Errorf("Expecting %d moves to go after %v from %s, got %d", testCase.movesToGo, testCase.moves, testCase.fen, limits.MovesToGo)
+		}
+	}
+}
+
+func Test_cecpResult(t *testing.T) {
+	cases := [][]string{
+		[]string{"7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", "1-0 {White mates}"},
+		[]string{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "1/2-1/2 {Stalemate}"},
+		[]string{"7k/8/6K1/8/8/8/8/8 b - - 0 1", "1/2-1/2 {Insufficient material}"},
+		[]string{"7k/5Q2/8/6K1/8/8/8/8 b - - 100 80", "1/2-1/2 {Fifty move rule}"},
+		[]string{StartingPositionFEN, ""},
+	}
+	for _, testCase := range cases {
+		if result := cecpResult(mustParseFEN(testCase[0])); result != testCase[1] {
+			t.Errorf("Expecting '%s' for %s, got '%s'", testCase[1], testCase[0], result)
+		}
+	}
+}
+
+func Test_cecpThinkingOutput(t *testing.T) {
+	cases := [][]string{
+		[]string{"info depth 3 seldepth 5 score cp 25 nodes 1000 nps 20000 time 50 pv e2e4 e7e5", "3 25 5 1000 e2e4 e7e5"},
+		[]string{"info depth 2 seldepth 2 multipv 2 score mate 1 nodes 10 nps 0 time 0 pv f7g7", "2 100001 0 10 f7g7"},
+		[]string{"info depth 4 score mate -2 nodes 10 time 1230 pv a1a2", "4 -100002 123 10 a1a2"},
+		[]string{"info string debug mode on", ""},
+		[]string{"bestmove e2e4", ""},
+	}
+	for _, testCase := range cases {
+		output, _ := cecpThinkingOutput(testCase[0])
+		if output != testCase[1] {
+			t.Errorf("Expecting '%s' for '%s', got '%s'", testCase[1], testCase[0], output)
+		}
+	}
+}
+
+func mustParseFEN(fen string) *Game {
+	game, err := ParseFEN(fen)
+	if err != nil {
+		panic(err)
+	}
+	return game
+}
@@ -3,36 +3,49 @@ package main
 import (
 	"bufio"
 	"os"
-	"strconv"
 
 	"github.com/bspaans/chess_engine"
 )
 
 func main() {
 	var engine chess_engine.Engine
-	engine = chess_engine.NewBSEngine(4)
-	for i, arg := range os.Args {
+	engine = chess_engine.NewBSEngine(chess_engine.DefaultSelDepth)
+	// Select the engine first, so that the other flags can be given in any
+	// order.
+	for _, arg := range os.Args {
 		if arg == "--random" {
 			engine = chess_engine.NewRandomEngine()
-		} else if arg == "--naive-material" {
-			engine.AddEvaluator(chess_engine.NaiveMaterialEvaluator)
-		} else if arg == "--space" {
-			engine.AddEvaluator(chess_engine.SpaceEvaluator)
-		} else if arg == "--tempo" {
-			engine.AddEvaluator(chess_engine.TempoEvaluator)
-		} else if arg == "--mobility" {
-			engine.AddEvaluator(chess_engine.MobilityEvaluator)
-		} else if arg == "--pawn-structure" {
-			engine.AddEvaluator(chess_engine.PawnStructureEvaluator)
-		} else if arg == "--depth" {
-			selDepth, err := strconv.Atoi(os.Args[i+1])
-			if err != nil {
+		} else if arg == "--alpha-beta" {
+			engine = chess_engine.NewAlphaBetaEngine(chess_engine.DefaultSelDepth)
+		}
+	}
+	uci := chess_engine.NewUCI("bs-engine", "Bart Spaans", engine)
+	// The flags set the same options that a GUI can change, so that they
+	// are advertised correctly.
+	for i, arg := range os.Args {
+		if arg == "--depth" && i+1 < len(os.Args) {
+			if err := uci.SetOption("Depth", os.Args[i+1]); err != nil {
+				panic(err)
+			}
+		} else if arg == "--log" && i+1 < len(os.Args) {
+			if err := uci.SetOption("Debug Log File", os.Args[i+1]); err != nil {
 				panic(err)
 			}
-			engine.SetOption(chess_engine.SELDEPTH, selDepth)
+		}
+		for _, named := range chess_engine.NamedEvaluators {
+			if arg == "--"+named.Name {
+				if err := uci.SetOption(named.Name, "true"); err != nil {
+					panic(err)
+				}
+			}
 		}
 	}
-	uci := chess_engine.NewUCI("bs-engine", "Bart Spaans", engine)
 	reader := bufio.NewReader(os.Stdin)
+	for _, arg := range os.Args {
+		if arg == "--xboard" {
+			chess_engine.NewCECP("bs-engine", engine).Start(reader)
+			return
+		}
+	}
 	uci.Start(reader)
 }
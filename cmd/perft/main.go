@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bspaans/chess_engine"
+)
+
+const usage = `Usage: perft [options]
+
+Counts the positions that can be reached from a position, to check the move
+generator against known results.
+
+Options:
+  --fen FEN         The position to start from (default the starting position)
+  --depth N         Count up to N plies deep (default 5)
+  --divide          Print the counts for every move in the root position at
+                    the final depth, so they can be compared with another
+                    engine's to find a bug
+  --epd FILE        Check every position in FILE against its D1..Dn operations,
+                    up to --depth if it's given
+  --hash MB         Use a hash table of MB megabytes
+  --workers N       Split the moves in the root position over N goroutines
+                    (default the number of CPUs)
+`
+
+func main() {
+	fen := chess_engine.StartingPositionFEN
+	depth := 0
+	divide := false
+	epdFile := ""
+	hashSize := 0
+	workers := runtime.NumCPU()
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		hasValue := i+1 < len(os.Args)
+		if arg == "--divide" {
+			divide = true
+		} else if arg == "--fen" && hasValue {
+			fen = os.Args[i+1]
+			i++
+		} else if arg == "--epd" && hasValue {
+			epdFile = os.Args[i+1]
+			i++
+		} else if (arg == "--depth" || arg == "--hash" || arg == "--workers") && hasValue {
+			value, err := strconv.Atoi(os.Args[i+1])
+			if err != nil || value <= 0 {
+				fail(fmt.Sprintf("Invalid value for %s: %s", arg, os.Args[i+1]))
+			}
+			if arg == "--depth" {
+				depth = value
+			} else if arg == "--hash" {
+				hashSize = value
+			} else {
+				workers = value
+			}
+			i++
+		} else {
+			fail("Unknown argument: " + arg)
+		}
+	}
+	var hash *chess_engine.PerftHashTable
+	if hashSize > 0 {
+		hash = chess_engine.NewPerftHashTable(hashSize)
+	}
+	if epdFile != "" {
+		if !runSuite(epdFile, depth, workers, hash) {
+			os.Exit(1)
+		}
+		return
+	}
+	game, err := chess_engine.ParseFEN(fen)
+	if err != nil {
+		fail(err.Error())
+	}
+	if depth == 0 {
+		depth = 5
+	}
+	runPosition(game, depth, workers, divide, hash)
+}
+
+func fail(msg string) {
+	if msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(1)
+}
+
+// Prints the counts for every depth, like the tables on the chess
+// programming wiki.
+func runPosition(game *chess_engine.Game, depth, workers int, divide bool, hash *chess_engine.PerftHashTable) {
+	fmt.Printf("%5s %12s %10s %8s %8s %8s %10s %8s %10s\n",
+		"Depth", "Nodes", "Captures", "E.p.", "Castles", "Promos", "Checks", "Mates", "Time")
+	for d := 1; d <= depth; d++ {
+		start := time.Now()
+		result := chess_engine.PerftParallel(game, d, workers, hash)
+		fmt.Printf("%5d %12d %10d %8d %8d %8d %10d %8d %10s\n",
+			d, result.Nodes, result.Captures, result.EnPassants, result.Castles,
+			result.Promotions, result.Checks, result.Mates, time.Since(start).Round(time.Millisecond))
+	}
+	if divide {
+		fmt.Println()
+		total := 0
+		for _, division := range chess_engine.PerftDivideParallel(game, depth, workers, hash) {
+			fmt.Printf("%s: %d\n", division.Move, division.Nodes)
+			total += division.Nodes
+		}
+		fmt.Printf("\nNodes searched: %d\n", total)
+	}
+}
+
+// Checks every position in the suite and returns whether they all passed.
+func runSuite(file string, maxDepth, workers int, hash *chess_engine.PerftHashTable) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		fail(err.Error())
+	}
+	epds, err := chess_engine.ReadEPD(f)
+	f.Close()
+	if err != nil {
+		fail(err.Error())
+	}
+	passed, failed := 0, 0
+	for i, epd := range epds {
+		id := epd.ID()
+		if id == "" {
+			id = "#" + strconv.Itoa(i+1)
+		}
+		counts, err := epd.PerftCounts()
+		if err != nil {
+			fmt.Printf("%-20s error: %s\n", id, err.Error())
+			failed++
+			continue
+		}
+		depths := []int{}
+		for d := range counts {
+			if maxDepth == 0 || d <= maxDepth {
+				depths = append(depths, d)
+			}
+		}
+		sort.Ints(depths)
+		for _, d := range depths {
+			start := time.Now()
+			nodes := chess_engine.PerftParallel(epd.Position, d, workers, hash).Nodes
+			status := "ok"
+			if nodes != counts[d] {
+				status = fmt.Sprintf("FAILED, expecting %d (diff %d)", counts[d], nodes-counts[d])
+				failed++
+			} else {
+				passed++
+			}
+			fmt.Printf("%-20s D%d %12d %10s  %s\n", id, d, nodes, time.Since(start).Round(time.Millisecond), status)
+		}
+	}
+	fmt.Printf("\nPassed %d/%d\n", passed, passed+failed)
+	return failed == 0
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bspaans/chess_engine"
+)
+
+const usage = `Usage: testsuite [options] FILE.epd
+
+Runs an engine on every position in an EPD test suite (e.g. WAC or STS) and
+checks its moves against the bm and am operations.
+
+Options:
+  --alpha-beta      Use the alpha-beta search instead of the default search
+  --random          Select random moves
+  --engine PATH     Run an external UCI engine instead
+  --<evaluator>     Enable an evaluator, e.g. --naive-material
+  --depth N         Search N plies deep
+  --nodes N         Search N nodes
+  --movetime MS     Search for MS milliseconds (default 1000)
+`
+
+// The upper bounds of the buckets in the solve time histogram.
+var histogramBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+func main() {
+	var engine chess_engine.Engine
+	engine = chess_engine.NewBSEngine(chess_engine.DefaultSelDepth)
+	limits := chess_engine.NewSearchLimits()
+	evaluators := []string{}
+	file := ""
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		hasValue := i+1 < len(os.Args)
+		if arg == "--alpha-beta" {
+			engine = chess_engine.NewAlphaBetaEngine(chess_engine.DefaultSelDepth)
+		} else if arg == "--random" {
+			engine = chess_engine.NewRandomEngine()
+		} else if arg == "--engine" && hasValue {
+			engine = chess_engine.NewExternalEngine(os.Args[i+1])
+			i++
+		} else if (arg == "--depth" || arg == "--nodes" || arg == "--movetime") && hasValue {
+			value, err := strconv.Atoi(os.Args[i+1])
+			if err != nil || value <= 0 {
+				fail(fmt.Sprintf("Invalid value for %s: %s", arg, os.Args[i+1]))
+			}
+			if arg == "--depth" {
+				limits.Depth = value
+			} else if arg == "--nodes" {
+				limits.Nodes = value
+			} else {
+				limits.MoveTime = time.Duration(value) * time.Millisecond
+			}
+			i++
+		} else if strings.HasPrefix(arg, "--") {
+			evaluators = append(evaluators, strings.TrimPrefix(arg, "--"))
+		} else {
+			file = arg
+		}
+	}
+	if file == "" {
+		fail("")
+	}
+	if limits.Depth == 0 && limits.Nodes == 0 && limits.MoveTime == 0 {
+		limits.MoveTime = time.Second
+	}
+	if _, ok := engine.(*chess_engine.ExternalEngine); !ok {
+		if err := engine.SetOption(chess_engine.EVALUATORS, chess_engine.StringValue(strings.Join(evaluators, ","))); err != nil {
+			fail(err.Error())
+		}
+	} else if len(evaluators) > 0 {
+		fail("Evaluators can't be used with an external engine")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fail(err.Error())
+	}
+	epds, err := chess_engine.ReadEPD(f)
+	f.Close()
+	if err != nil {
+		fail(err.Error())
+	}
+
+	results := []*chess_engine.TestSuiteResult{}
+	for i, epd := range epds {
+		id := epd.ID()
+		if id == "" {
+			id = "#" + strconv.Itoa(i+1)
+		}
+		engine.NewGame()
+		result, err := chess_engine.RunTestSuitePosition(engine, epd, limits)
+		if err != nil {
+			fmt.Printf("%-20s error: %s\n", id, err.Error())
+			continue
+		}
+		results = append(results, result)
+		fmt.Println(formatResult(id, result))
+	}
+	if external, ok := engine.(*chess_engine.ExternalEngine); ok {
+		external.Close()
+	}
+	fmt.Println()
+	fmt.Println(summary(results))
+}
+
+func fail(msg string) {
+	if msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(1)
+}
+
+// Formats the result for a single position, e.g.
+// "WAC.001   solved  Qg6  (bm Qg6)     0.12s   1234 nodes"
+func formatResult(id string, result *chess_engine.TestSuiteResult) string {
+	status := "failed"
+	if result.Solved {
+		status = "solved"
+	}
+	move := "(none)"
+	if result.BestMove != nil {
+		move = chess_engine.MoveToAlgebraicMove(result.EPD.Position, result.BestMove)
+	}
+	expected := []string{}
+	for _, opcode := range []string{"bm", "am"} {
+		if op := result.EPD.GetOperation(opcode); op != nil {
+			expected = append(expected, opcode+" "+strings.Join(op.Operands, " "))
+		}
+	}
+	solveTime := ""
+	if result.Solved {
+		solveTime = fmt.Sprintf("%.2fs", result.SolveTime.Seconds())
+	}
+	return fmt.Sprintf("%-20s %s  %-8s (%s)  %8s  %10d nodes", id, status, move, strings.Join(expected, "; "), solveTime, result.Nodes)
+}
+
+// Returns the score and a histogram of the solve times.
+func summary(results []*chess_engine.TestSuiteResult) string {
+	solved := 0
+	counts := make([]int, len(histogramBuckets)+1)
+	for _, result := range results {
+		if !result.Solved {
+			continue
+		}
+		solved++
+		bucket := len(histogramBuckets)
+		for i, limit := range histogramBuckets {
+			if result.SolveTime < limit {
+				bucket = i
+				break
+			}
+		}
+		counts[bucket]++
+	}
+	percentage := 0.0
+	if len(results) > 0 {
+		percentage = float64(solved) * 100 / float64(len(results))
+	}
+	str := fmt.Sprintf("Solved %d/%d (%.1f%%)\n\nSolve times:\n", solved, len(results), percentage)
+	for i, count := range counts {
+		label := ""
+		if i < len(histogramBuckets) {
+			label = "< " + histogramBuckets[i].String()
+		} else {
+			label = ">= " + histogramBuckets[len(histogramBuckets)-1].String()
+		}
+		str += strings.TrimRight(fmt.Sprintf("  %-8s %4d %s", label, count, strings.Repeat("#", count)), " ") + "\n"
+	}
+	return str
+}
@@ -12,17 +12,35 @@ type BSEngine struct {
 	Evaluators       Evaluators
 	EvalTree         *EvalTree
 	SelDepth         int
+	// The number of best lines to report
+	MultiPV int
 
 	TotalNodes     int
 	NodesPerSecond int
 	CurrentDepth   int
+	StartTime      time.Time
 	Seen           SeenMap
 	Queue          *Queue
+	TimeManager    *TimeManager
+	Limits         *SearchLimits
+	// The positions whose alternatives were queued after a quiescence
+	// search showed a blunder.
+	Requeued SeenMap
+	// The quiescence scores of the leaves in the current iteration
+	QuiescenceScores map[uint64]Score
+
+	// Search results that are kept between searches
+	TranspositionTable *TranspositionTable
+	// The part of the previous EvalTree that is still relevant for the
+	// current position, if any.
+	ReusedEvalTree *EvalTree
 }
 
 func NewBSEngine(depth int) *BSEngine {
 	return &BSEngine{
-		SelDepth: depth,
+		SelDepth:           depth,
+		MultiPV:            1,
+		TranspositionTable: NewTranspositionTable(DefaultHashSize),
 	}
 }
 
@@ -31,73 +49,237 @@ func (b *BSEngine) GetPosition() *Game {
 }
 
 func (b *BSEngine) SetPosition(fen *Game) {
+	b.ReusedEvalTree = b.reuseEvalTree(fen)
 	b.StartingPosition = fen
 }
 
-func (b *BSEngine) SetOption(opt EngineOption, val int) {
+// If @game follows from the position we searched last, we can keep the part
+// of the EvalTree that's below the moves that were played since. Returns nil
+// if there's nothing to reuse.
+func (b *BSEngine) reuseEvalTree(game *Game) *EvalTree {
+	if b.StartingPosition == nil || b.EvalTree == nil {
+		return nil
+	}
+	// The GUI sends us new Games, so we have to find the previous position
+	// by hash.
+	positions := []*Game{}
+	found := false
+	for g := game; g != nil; g = g.Parent {
+		if g.Hash == b.StartingPosition.Hash {
+			found = true
+			break
+		}
+		positions = append([]*Game{g}, positions...)
+	}
+	if !found {
+		return nil
+	}
+	tree := b.EvalTree
+	previous := b.StartingPosition
+	for _, position := range positions {
+		var next *EvalTree
+		for _, reply := range tree.Replies {
+			if previous.ApplyMove(reply.Move).Hash == position.Hash {
+				next = reply
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		tree, previous = next, position
+	}
+	if len(tree.Replies) == 0 {
+		return nil
+	}
+	return tree.Detach(len(positions))
+}
+
+// Threads are not supported yet, so they are ignored.
+func (b *BSEngine) SetOption(opt EngineOption, val OptionValue) error {
 	if opt == SELDEPTH {
-		b.SelDepth = val
+		b.SelDepth = val.Int
+	} else if opt == MULTIPV {
+		b.MultiPV = val.Int
+	} else if opt == HASH {
+		b.TranspositionTable = NewTranspositionTable(val.Int)
+	} else if opt == EVALUATORS {
+		evaluators, err := ParseEvaluators(val.String)
+		if err != nil {
+			return err
+		}
+		b.Evaluators = evaluators
+		// The scores in the EvalTree are no longer comparable
+		b.EvalTree = nil
+		b.ReusedEvalTree = nil
 	}
+	return nil
 }
 
-func (b *BSEngine) Start(output chan string, maxNodes, maxDepth int) {
-	ctx, cancel := context.WithCancel(context.Background())
+// Forgets everything we've learned in the previous game.
+func (b *BSEngine) NewGame() {
+	b.TranspositionTable.Clear()
+	b.EvalTree = nil
+	b.ReusedEvalTree = nil
+}
+
+func (b *BSEngine) Start(output chan string, limits *SearchLimits) {
+	if limits == nil {
+		limits = NewSearchLimits()
+	}
+	timeManager := NewTimeManager(limits, b.StartingPosition.ToMove)
+	ctx, cancel := timeManager.Start(context.Background())
 	b.Cancel = cancel
-	go b.start(ctx, output, maxNodes, maxDepth)
+	b.TimeManager = timeManager
+	b.Limits = limits
+	go b.start(ctx, output, limits, timeManager)
 }
 
-func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxDepth int) {
-	b.Seen = NewSeenMap()
-	b.EvalTree = NewEvalTree(nil)
+func (b *BSEngine) start(ctx context.Context, output chan string, limits *SearchLimits, timeManager *TimeManager) {
 	b.NodesPerSecond = 0
 	b.TotalNodes = 0
+	b.CurrentDepth = 0
+	b.StartTime = time.Now()
+	b.TranspositionTable.NewSearch()
+	if err := limits.CheckSearchMoves(b.StartingPosition); err != nil {
+		output <- "info string error: " + err.Error()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Iterative deepening: we search to depth 1, 2, ... so that we always
+	// have a best move from the last completed iteration when we're asked
+	// to stop.
+	// If we've searched this position before, we look at the best move we
+	// found back then first.
+	previousBestMove := b.getTranspositionTableMove(b.StartingPosition)
+
+	// A depth or mate limit from the GUI takes precedence over the SelDepth
+	// option.
+	maxDepth := limits.MaxDepth(b.SelDepth)
+
+	// The first iteration starts from what we already know about this
+	// position from the previous search, if anything. That search wasn't
+	// restricted to the searchmoves, so we can't use it if there are any.
+	var tree *EvalTree
+	if len(limits.SearchMoves) == 0 {
+		tree = b.ReusedEvalTree
+	}
+	b.ReusedEvalTree = nil
+
+	var completed *EvalTree
+	for depth := 1; depth <= maxDepth; depth++ {
+		if depth > 1 && !timeManager.CanStartIteration() {
+			break
+		}
+		if tree == nil {
+			tree = NewEvalTree(nil)
+		}
+		if !b.search(ctx, output, ticker.C, tree, depth, limits, previousBestMove) {
+			break
+		}
+		completed = b.EvalTree
+		tree = nil
+		if b.EvalTree.BestLine == nil {
+			// There are no moves to search, so there's nothing to report.
+			break
+		}
+		b.CurrentDepth = depth
+		b.storeBestLine()
+		b.outputInfo(output)
+		if b.EvalTree.Score.IsMateInNOrBetter(depth) || limits.IsMateFound(b.EvalTree.Score) {
+			break
+		}
+	}
+	if completed != nil {
+		b.EvalTree = completed
+	}
+	timeManager.WaitForStop(ctx)
+	b.outputBestMove(output)
+}
+
+// Searches the starting position up to the given depth, adding the results
+// to the given tree. Returns false if the search was interrupted before it
+// could complete.
+func (b *BSEngine) search(ctx context.Context, output chan string, ticker <-chan time.Time, tree *EvalTree, depth int, limits *SearchLimits, previousBestMove *Move) bool {
+	b.Seen = NewSeenMap()
+	b.Requeued = NewSeenMap()
+	b.QuiescenceScores = map[uint64]Score{}
+	b.EvalTree = tree
 	b.Queue = NewQueue()
 
-	timer := time.NewTimer(time.Second)
-	//depth := b.SelDepth + 1
+	// Root moves that we're not allowed to search are treated as if we've
+	// already seen them, so that they never get queued.
+	for _, next := range b.StartingPosition.NextGames() {
+		if !limits.IsSearchMove(next.Line[len(next.Line)-1]) {
+			b.Seen.Set(next)
+		}
+	}
+
+	if depth == 1 {
+		// At depth 1 we can afford to look at every move, which makes sure
+		// we always find the best move on static evaluation.
+		b.Queue.QueueAllMoves(b.StartingPosition, b.Seen)
+	} else {
+		b.Queue.QueueNextLine(b.StartingPosition, b.Seen, depth, b.Evaluators)
+	}
 
-	b.Queue.QueueNextLine(b.StartingPosition, b.Seen, b.SelDepth, b.Evaluators)
+	if previousBestMove != nil {
+		next := b.StartingPosition.ApplyMove(previousBestMove)
+		if !b.Seen.Seen(next) {
+			b.Queue.QueueToQuietPosition(next, b.Seen, depth, b.Evaluators)
+		}
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			b.outputInfo(output, true)
-			return
-		case <-timer.C:
-			b.TotalNodes += b.NodesPerSecond
-			b.NodesPerSecond = 0
-			timer = time.NewTimer(time.Second)
-			b.outputInfo(output, false)
+			return false
+		case <-ticker:
+			b.outputProgress(output)
 		default:
-			if maxNodes > 0 && b.TotalNodes+b.NodesPerSecond >= maxNodes {
-				b.outputInfo(output, true)
-				return
+			if limits.Nodes > 0 && b.TotalNodes >= limits.Nodes {
+				return false
 			}
 			if !b.Queue.IsEmpty() {
 				game := b.Queue.GetNextGame()
 				if game == nil {
 					panic("game nil")
 				}
+				b.TotalNodes++
 				if game.Score == nil {
 					b.Evaluators.Eval(game)
 				}
 				if *game.Score == Mate {
 					*game.Score = *game.Score - Score(float64(len(game.Line)))
 				}
+				if len(game.Line) == depth && !game.IsFinished() {
+					// The next iterations order their moves by the cached
+					// score, so that should be the resolved one as well.
+					score := b.quiescence(game, depth)
+					game.Score = &score
+				}
 				b.EvalTree.Insert(game.Line, *game.Score)
 
-				if len(game.Line) == 0 || len(game.Line) == b.SelDepth {
+				if len(game.Line) == 0 || len(game.Line) == depth {
 					b.EvalTree.UpdateBestLine()
 					//if b.EvalTree.Score == Mate {
 					//	b.outputInfo(output, true)
 					//	return
 					//}
-				} else if len(game.Line) < b.SelDepth {
+				} else if len(game.Line) < depth {
 					// If we already found Mate at this depth we can skip
 					// this whole tree
 					if b.EvalTree.Score.IsMateInNOrBetter(len(game.Line)) {
 						continue
 					}
+					// If a previous search already looked at this position
+					// deeply enough we can reuse its result instead of
+					// expanding the tree any further.
+					if b.insertTranspositionTableResult(game, depth) {
+						continue
+					}
 					debug := false
 					// Check if the score difference between this line
 					// and the parent is not too big. If it is we should
@@ -105,7 +287,7 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 
 					tree := b.EvalTree.Traverse(game.Line[:len(game.Line)])
 
-					queuedForcingLines := b.Queue.QueueForcingLines(game, b.Seen, b.SelDepth-len(game.Line), b.Evaluators)
+					queuedForcingLines := b.Queue.QueueForcingLines(game, b.Seen, depth-len(game.Line), b.Evaluators)
 
 					// The root position is already looked after
 					if len(game.Line) == 1 || queuedForcingLines {
@@ -133,7 +315,7 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 							// So we should have moved something differently
 							// before. Queue the next line from the parent's parent.
 							//fmt.Println("Major loss for", game.ToMove.Opposite(), game.Line, diff, tree.Score, *game.Parent.Score)
-							if b.Queue.QueueNextLine(game.Parent, b.Seen, b.SelDepth-len(game.Parent.Line), b.Evaluators) {
+							if b.Queue.QueueNextLine(game.Parent, b.Seen, depth-len(game.Parent.Line), b.Evaluators) {
 							}
 						}
 					}
@@ -142,22 +324,22 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 				// The queue is empty so there are no more moves to look at.
 				// However we can queue more moves if it turns out our current
 				// best move leads to a worse position than what we started with.
+				// We also need at least as many root moves as the number of
+				// lines we're reporting.
 				firstScore := *b.StartingPosition.Score * -1
-				if b.EvalTree.BestLine == nil || firstScore > b.EvalTree.BestLine.Score {
+				if b.EvalTree.BestLine == nil || firstScore > b.EvalTree.BestLine.Score || len(b.EvalTree.Replies) < b.MultiPV {
 					// Queue forcing lines, than queue alternative best moves
 					//fmt.Println("queue alternative...why?", b.EvalTree.BestLine)
-					hasNext := b.Queue.QueueNextLine(b.StartingPosition, b.Seen, b.SelDepth, b.Evaluators)
+					hasNext := b.Queue.QueueNextLine(b.StartingPosition, b.Seen, depth, b.Evaluators)
 					if !hasNext {
 						//fmt.Println("we are losing")
-						b.outputInfo(output, true)
-						return
+						return true
 					}
 				} else {
 					//fmt.Println("We are better", *b.StartingPosition.Score, b.EvalTree.BestLine.Score)
 					//fmt.Println(Line(b.EvalTree.BestLine.GetBestLine().Line).String())
-					// Otherwise output the best move
-					b.outputInfo(output, true)
-					return
+					// Otherwise we're done
+					return true
 				}
 
 			}
@@ -165,25 +347,166 @@ func (b *BSEngine) start(ctx context.Context, output chan string, maxNodes, maxD
 	}
 }
 
-func (b *BSEngine) outputInfo(output chan string, sendBestMove bool) {
-	bestLine := b.EvalTree.BestLine
-	bestResult := bestLine.GetBestLine()
-	line := Line(bestResult.Line).String()
-	output <- fmt.Sprintf("info depth %d ns %d nodes %d score cp %d pv %s",
-		len(bestResult.Line),
-		b.NodesPerSecond,
-		b.TotalNodes,
-		bestResult.Score.ToCentipawn(),
-		line)
-	if sendBestMove {
+// Extends a leaf over captures, so that we don't stop evaluating in the
+// middle of an exchange. Every position is only resolved once per
+// iteration, even if we get to it again through a transposition or after
+// its parent was queued again.
+func (b *BSEngine) quiescence(game *Game, depth int) Score {
+	if score, ok := b.QuiescenceScores[game.Hash]; ok {
+		return score
+	}
+	// There's nothing to resolve if the side to move can't capture
+	// anything and isn't in check.
+	if !game.InCheck() && len(b.Evaluators.quiescenceMoves(game, false)) == 0 {
+		b.QuiescenceScores[game.Hash] = *game.Score
+		return *game.Score
+	}
+	score, nodes := b.Evaluators.Quiescence(game, LowestScore+1, -(LowestScore + 1), len(game.Line))
+	score = score * -1
+	b.TotalNodes += nodes - 1
+	// If the exchange changes the evaluation significantly the previous
+	// move might have been a blunder, so we should consider some
+	// alternatives. We only do this once per parent, otherwise every
+	// sibling would queue the same moves again.
+	diff := score - *game.Score
+	if diff < 0 {
+		diff *= -1
+	}
+	if diff > 200 && !b.Requeued.Seen(game.Parent) {
+		b.Requeued.Set(game.Parent)
+		b.Queue.QueueNextLine(game.Parent, b.Seen, depth-len(game.Parent.Line), b.Evaluators)
+	}
+	b.QuiescenceScores[game.Hash] = score
+	return score
+}
+
+// Returns the best move stored in the transposition table for this position,
+// as long as it's a valid move.
+func (b *BSEngine) getTranspositionTableMove(game *Game) *Move {
+	entry := b.TranspositionTable.Probe(game.Hash)
+	if entry == nil || entry.BestMove == nil {
+		return nil
+	}
+	for _, move := range game.ValidMoves() {
+		if *move == *entry.BestMove {
+			return move
+		}
+	}
+	return nil
+}
+
+// Inserts the best reply and score from the transposition table into the
+// EvalTree if the stored entry has been searched at least as deep as we
+// would search this game now, given the @depth of the current iteration.
+func (b *BSEngine) insertTranspositionTableResult(game *Game, depth int) bool {
+	entry := b.TranspositionTable.Probe(game.Hash)
+	if entry == nil || entry.Bound != ExactBound || int(entry.Depth) < depth-len(game.Line) {
+		return false
+	}
+	move := b.getTranspositionTableMove(game)
+	if move == nil {
+		return false
+	}
+	line := make([]*Move, len(game.Line)+1)
+	copy(line, game.Line)
+	line[len(game.Line)] = move
+	b.EvalTree.Insert(line, entry.GetScore(len(game.Line)))
+	return true
+}
+
+// Stores the best line in the transposition table so that subsequent
+// searches can use it for move ordering and cutoffs.
+func (b *BSEngine) storeBestLine() {
+	nodes := []*EvalTree{}
+	for tree := b.EvalTree; tree != nil && tree.BestLine != nil; tree = tree.BestLine {
+		nodes = append(nodes, tree)
+	}
+	game := b.StartingPosition
+	for ply, tree := range nodes {
+		// The root's score is from the perspective of the side to move, but
+		// the other nodes are scored from the perspective of the side that
+		// just moved.
+		score := tree.Score
+		if tree.Parent != nil {
+			score = score * -1
+		}
+		b.TranspositionTable.Store(game.Hash, ply, len(nodes)-ply, score, ExactBound, tree.BestLine.Move)
+		game = game.ApplyMove(tree.BestLine.Move)
+	}
+}
+
+func (b *BSEngine) outputInfo(output chan string) {
+	elapsed := time.Since(b.StartTime)
+	b.NodesPerSecond = nodesPerSecond(b.TotalNodes, elapsed)
+	results := []*EvalResult{b.EvalTree.GetBestLine()}
+	if b.MultiPV > 1 {
+		results = b.EvalTree.GetBestLines(b.MultiPV)
+	}
+	for i, result := range results {
+		multiPV := ""
+		if b.MultiPV > 1 {
+			multiPV = fmt.Sprintf(" multipv %d", i+1)
+		}
+		output <- fmt.Sprintf("info depth %d seldepth %d%s score %s nodes %d nps %d time %d pv %s",
+			b.CurrentDepth,
+			b.EvalTree.MaxDepth()-1,
+			multiPV,
+			result.Score.ToUCI(),
+			b.TotalNodes,
+			b.NodesPerSecond,
+			elapsed.Milliseconds(),
+			Line(result.Line).String())
+	}
+}
+
+// Periodically lets the GUI know we're still searching.
+func (b *BSEngine) outputProgress(output chan string) {
+	elapsed := time.Since(b.StartTime)
+	b.NodesPerSecond = nodesPerSecond(b.TotalNodes, elapsed)
+	output <- fmt.Sprintf("info nodes %d nps %d time %d", b.TotalNodes, b.NodesPerSecond, elapsed.Milliseconds())
+}
+
+func (b *BSEngine) outputBestMove(output chan string) {
+	if b.EvalTree != nil && b.EvalTree.BestLine != nil {
+		bestLine := b.EvalTree.BestLine
+		if bestLine.BestLine != nil {
+			// The second move in our line is the reply we expect, so that's
+			// what the GUI can let us ponder on.
+			output <- fmt.Sprintf("bestmove %s ponder %s", bestLine.Move.String(), bestLine.BestLine.Move.String())
+			return
+		}
 		output <- fmt.Sprintf("bestmove %s", bestLine.Move.String())
+		return
+	}
+	// We didn't get far enough to have a best line, so any valid move will
+	// do.
+	moves := b.Limits.FilterMoves(b.StartingPosition.ValidMoves())
+	if len(moves) == 0 {
+		output <- "bestmove (none)"
+		return
+	}
+	output <- fmt.Sprintf("bestmove %s", moves[0].String())
+}
+
+func nodesPerSecond(nodes int, elapsed time.Duration) int {
+	if elapsed <= 0 {
+		return 0
 	}
+	return int(float64(nodes) / elapsed.Seconds())
 }
 
 func (b *BSEngine) AddEvaluator(e Evaluator) {
 	b.Evaluators = append(b.Evaluators, e)
 }
 
+func (b *BSEngine) PonderHit() {
+	if b.TimeManager != nil {
+		b.TimeManager.PonderHit()
+	}
+}
+
 func (b *BSEngine) Stop() {
-	b.Cancel()
+	if b.Cancel != nil {
+		b.Cancel()
+	}
 }
@@ -19,12 +19,10 @@ func isTestEnabled(t *testing.T, name ...string) bool {
 	return false
 }
 
-func getBestMove(unit *BSEngine, timeLimit time.Duration) string {
+func getBestMove(unit Engine, timeLimit time.Duration) string {
 	outputs := make(chan string, 1000)
-	maxDepth := 0
-	maxNodes := 0
 	//fmt.Println("Starting with position", unit.StartingPosition.FENString())
-	unit.Start(outputs, maxNodes, maxDepth)
+	unit.Start(outputs, NewSearchLimits())
 	defer unit.Stop()
 	timer := time.NewTimer(timeLimit)
 	finalTimer := time.NewTimer(timeLimit + 2*time.Second)
@@ -42,7 +40,7 @@ func getBestMove(unit *BSEngine, timeLimit time.Duration) string {
 		case output := <-outputs:
 			//fmt.Println("Received output", output)
 			if strings.HasPrefix(output, "bestmove ") {
-				bestmove = output[9:]
+				bestmove = strings.Fields(output)[1]
 				unit.Stop()
 				running = false
 			}
@@ -593,3 +591,463 @@ func Benchmark_MateInTwo(t *testing.B) {
 		}
 	}
 }
+
+func Test_Engine_outputs_info_per_depth(t *testing.T) {
+	fen, err := ParseFEN("rnbqkb1r/pppppppp/8/8/3PP1n1/8/PPP2PPP/RNBQKBNR w KQkq - 1 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	outputs := make(chan string, 1000)
+	unit.Start(outputs, NewSearchLimits())
+	depth := 1
+	for output := range outputs {
+		if strings.HasPrefix(output, "bestmove ") {
+			break
+		}
+		if !strings.HasPrefix(output, "info depth ") {
+			continue
+		}
+		expected := fmt.Sprintf("info depth %d seldepth ", depth)
+		if !strings.HasPrefix(output, expected) {
+			t.Errorf("Expecting info for depth %d, got %s", depth, output)
+		}
+		for _, field := range []string{" score cp ", " nodes ", " nps ", " time ", " pv "} {
+			if !strings.Contains(output, field) {
+				t.Errorf("Expecting%sin %s", field, output)
+			}
+		}
+		depth++
+	}
+	if depth != 4 {
+		t.Errorf("Expecting info for three iterations, got %d", depth-1)
+	}
+}
+
+func Test_Engine_has_bestmove_when_stopped_straight_away(t *testing.T) {
+	fen, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(20)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, time.Millisecond)
+	move, err := ParseMove(bestmove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, valid := range fen.ValidMoves() {
+		if *valid == *move {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expecting a valid best move, got %s", bestmove)
+	}
+}
+
+func Test_Engine_stops_when_movetime_runs_out(t *testing.T) {
+	fen, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20)} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		limits := NewSearchLimits()
+		limits.MoveTime = 100 * time.Millisecond
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, limits)
+		timer := time.NewTimer(2 * time.Second)
+		bestmove := ""
+		for bestmove == "" {
+			select {
+			case <-timer.C:
+				unit.Stop()
+				t.Fatalf("Expecting the search to stop by itself")
+			case output := <-outputs:
+				if strings.HasPrefix(output, "bestmove ") {
+					bestmove = output
+				}
+			}
+		}
+		timer.Stop()
+	}
+}
+
+// Runs a search with the given limits until the engine reports its best move
+// and returns the info lines that were output per depth, and the best move.
+func searchWithLimits(unit Engine, limits *SearchLimits) ([]string, string) {
+	outputs := make(chan string, 1000)
+	unit.Start(outputs, limits)
+	infos := []string{}
+	for output := range outputs {
+		if strings.HasPrefix(output, "bestmove ") {
+			return infos, strings.Fields(output)[1]
+		}
+		if strings.HasPrefix(output, "info depth ") {
+			infos = append(infos, output)
+		}
+	}
+	return infos, ""
+}
+
+func Test_Engine_depth_1_returns_the_best_static_move(t *testing.T) {
+	fen, err := ParseFEN("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20)} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		limits := NewSearchLimits()
+		limits.Depth = 1
+		infos, bestmove := searchWithLimits(unit, limits)
+		if bestmove != "d2d5" {
+			t.Errorf("Expecting d2d5, got %s", bestmove)
+		}
+		if len(infos) != 1 || !strings.HasPrefix(infos[0], "info depth 1 ") {
+			t.Errorf("Expecting a single iteration at depth 1, got %v", infos)
+		}
+	}
+}
+
+func Test_Engine_searches_for_mate(t *testing.T) {
+	fen, err := ParseFEN("r1bq2r1/b4pk1/p1pp1p2/1p2pP2/1P2P1PB/3P4/1PPQ2P1/R3K2R w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The depth option is too shallow to find the mate in two, so the mate
+	// limit has to make the engines search deeper.
+	for _, unit := range []Engine{NewBSEngine(1), NewAlphaBetaEngine(1)} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.AddEvaluator(SpaceEvaluator)
+		unit.SetPosition(fen)
+		limits := NewSearchLimits()
+		limits.Mate = 2
+		infos, bestmove := searchWithLimits(unit, limits)
+		if bestmove != "d2h6" {
+			t.Errorf("Expecting d2h6, got %s", bestmove)
+		}
+		if len(infos) == 0 || !strings.Contains(infos[len(infos)-1], " score mate 2 ") {
+			t.Errorf("Expecting to find a mate in 2, got %v", infos)
+		}
+	}
+}
+
+func Test_Engine_deeper_limits_search_further(t *testing.T) {
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20)} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		nodes := []int{}
+		for _, depth := range []int{1, 3} {
+			unit.NewGame()
+			limits := NewSearchLimits()
+			limits.Depth = depth
+			infos, _ := searchWithLimits(unit, limits)
+			if len(infos) != depth {
+				t.Fatalf("Expecting %d iterations, got %v", depth, infos)
+			}
+			last := infos[len(infos)-1]
+			if !strings.HasPrefix(last, fmt.Sprintf("info depth %d ", depth)) {
+				t.Errorf("Expecting the last iteration to be at depth %d, got %s", depth, last)
+			}
+			fields := strings.Split(last, " ")
+			for i, field := range fields {
+				if field == "nodes" {
+					n, err := strconv.Atoi(fields[i+1])
+					if err != nil {
+						t.Fatal(err)
+					}
+					nodes = append(nodes, n)
+				}
+			}
+		}
+		if len(nodes) != 2 || nodes[1] <= nodes[0] {
+			t.Errorf("Expecting the deeper search to look at more nodes, got %v", nodes)
+		}
+	}
+}
+
+func Test_Engine_waits_for_ponderhit(t *testing.T) {
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(3), NewAlphaBetaEngine(3)} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		limits := NewSearchLimits()
+		limits.Ponder = true
+		limits.MoveTime = time.Second
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, limits)
+
+		timeout := time.After(200 * time.Millisecond)
+		waiting := true
+		for waiting {
+			select {
+			case output := <-outputs:
+				if strings.HasPrefix(output, "bestmove ") {
+					t.Fatalf("Not expecting a best move while pondering, got %s", output)
+				}
+			case <-timeout:
+				waiting = false
+			}
+		}
+
+		unit.PonderHit()
+		timeout = time.After(2 * time.Second)
+		for waiting = true; waiting; {
+			select {
+			case output := <-outputs:
+				if strings.HasPrefix(output, "bestmove ") {
+					parts := strings.Fields(output)
+					if len(parts) != 4 || parts[2] != "ponder" {
+						t.Errorf("Expecting a ponder move, got %s", output)
+					}
+					waiting = false
+				}
+			case <-timeout:
+				unit.Stop()
+				t.Fatalf("Expecting a best move after the ponderhit")
+			}
+		}
+	}
+}
+
+func Test_Engine_stop_while_pondering(t *testing.T) {
+	fen, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(2)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	limits := NewSearchLimits()
+	limits.Ponder = true
+	bestmove := ""
+	outputs := make(chan string, 1000)
+	unit.Start(outputs, limits)
+	time.Sleep(50 * time.Millisecond)
+	unit.Stop()
+	timeout := time.After(time.Second)
+	for bestmove == "" {
+		select {
+		case output := <-outputs:
+			if strings.HasPrefix(output, "bestmove ") {
+				bestmove = output
+			}
+		case <-timeout:
+			t.Fatalf("Expecting a best move after stopping")
+		}
+	}
+}
+
+func Test_Engine_MultiPV(t *testing.T) {
+	fen, err := ParseFEN("r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20)} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		if err := unit.SetOption(MULTIPV, IntValue(3)); err != nil {
+			t.Fatal(err)
+		}
+		limits := NewSearchLimits()
+		limits.Depth = 2
+		infos, bestmove := searchWithLimits(unit, limits)
+		lines := []string{}
+		for _, info := range infos {
+			if strings.HasPrefix(info, "info depth 2 ") {
+				lines = append(lines, info)
+			}
+		}
+		if len(lines) != 3 {
+			t.Fatalf("Expecting 3 lines at depth 2, got %v", lines)
+		}
+		seen := map[string]bool{}
+		previousScore := 0
+		for i, line := range lines {
+			fields := strings.Fields(line)
+			get := func(name string) string {
+				for j, field := range fields {
+					if field == name && j+1 < len(fields) {
+						return fields[j+1]
+					}
+				}
+				return ""
+			}
+			if get("multipv") != strconv.Itoa(i+1) {
+				t.Errorf("Expecting multipv %d in %s", i+1, line)
+			}
+			move := get("pv")
+			if seen[move] {
+				t.Errorf("Expecting a different first move in %s", line)
+			}
+			seen[move] = true
+			if i == 0 && move != bestmove {
+				t.Errorf("Expecting the first line to start with the best move %s, got %s", bestmove, line)
+			}
+			score, err := strconv.Atoi(get("cp"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i > 0 && score > previousScore {
+				t.Errorf("Expecting the lines to be ordered by score, got %v", lines)
+			}
+			previousScore = score
+		}
+	}
+}
+
+func Test_Engine_MultiPV_stops_when_it_runs_out_of_moves(t *testing.T) {
+	for _, fenString := range []string{
+		"7k/8/8/8/8/8/6PP/7K w - - 0 1",
+		// Stalemate
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1",
+	} {
+		fen, err := ParseFEN(fenString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20)} {
+			unit.AddEvaluator(NaiveMaterialEvaluator)
+			unit.SetPosition(fen)
+			if err := unit.SetOption(MULTIPV, IntValue(10)); err != nil {
+				t.Fatal(err)
+			}
+			limits := NewSearchLimits()
+			limits.Depth = 2
+			infos, _ := searchWithLimits(unit, limits)
+			lines := 0
+			for _, info := range infos {
+				if strings.HasPrefix(info, "info depth 2 ") {
+					lines++
+				}
+				if strings.HasSuffix(info, " pv ") || strings.Contains(info, strconv.Itoa(int(LowestScore))) {
+					t.Errorf("Expecting a line with moves and a score in %s, got %s", fenString, info)
+				}
+			}
+			if expected := len(fen.ValidMoves()); lines != expected {
+				t.Errorf("Expecting %d lines at depth 2 in %s, got %v", expected, fenString, infos)
+			}
+		}
+	}
+}
+
+func Test_Engine_only_searches_searchmoves(t *testing.T) {
+	fen, err := ParseFEN("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20), NewRandomEngine()} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		if err := unit.SetOption(MULTIPV, IntValue(3)); err != nil {
+			t.Fatal(err)
+		}
+		limits := NewSearchLimits()
+		limits.Depth = 2
+		limits.SearchMoves = []*Move{NewMove(D2, D3), NewMove(E1, F1)}
+		infos, bestmove := searchWithLimits(unit, limits)
+		if bestmove != "d2d3" && bestmove != "e1f1" {
+			t.Errorf("Expecting d2d3 or e1f1, got %s", bestmove)
+		}
+		for _, info := range infos {
+			pv := strings.Fields(info[strings.Index(info, " pv ")+4:])
+			if pv[0] != "d2d3" && pv[0] != "e1f1" {
+				t.Errorf("Expecting only lines starting with the search moves, got %s", info)
+			}
+		}
+	}
+}
+
+func Test_Engine_reports_searchmoves_that_cant_be_played(t *testing.T) {
+	fen, err := ParseFEN("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []Engine{NewBSEngine(20), NewAlphaBetaEngine(20), NewRandomEngine()} {
+		unit.AddEvaluator(NaiveMaterialEvaluator)
+		unit.SetPosition(fen)
+		limits := NewSearchLimits()
+		limits.Depth = 2
+		limits.SearchMoves = []*Move{NewMove(A1, A2)}
+		outputs := make(chan string, 1000)
+		unit.Start(outputs, limits)
+		reported := false
+		for output := range outputs {
+			if strings.HasPrefix(output, "info string error: ") {
+				reported = true
+			} else if strings.HasPrefix(output, "info ") {
+				t.Errorf("Expecting no search info, got %s", output)
+			} else if strings.HasPrefix(output, "bestmove ") {
+				if output != "bestmove (none)" {
+					t.Errorf("Expecting bestmove (none), got %s", output)
+				}
+				break
+			}
+		}
+		if !reported {
+			t.Errorf("Expecting an error for the searchmoves")
+		}
+	}
+}
+
+func Test_Engine_reuses_EvalTree_for_moves_played(t *testing.T) {
+	fen, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	limits := NewSearchLimits()
+	searchWithLimits(unit, limits)
+
+	line := unit.EvalTree.GetBestLine().Line
+	if len(line) < 3 {
+		t.Fatalf("Expecting a line of at least three moves, got %v", line)
+	}
+	expected := unit.EvalTree.Traverse(line[:2])
+	position, err := parsePositionCommand([]string{"startpos", "moves", line[0].String(), line[1].String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.SetPosition(position)
+	if unit.ReusedEvalTree == nil || unit.ReusedEvalTree != expected {
+		t.Fatalf("Expecting the subtree for %v to be reused", line[:2])
+	}
+	if unit.ReusedEvalTree.BestLine == nil || unit.ReusedEvalTree.BestLine.Move.String() != line[2].String() {
+		t.Errorf("Expecting best move %s in the reused tree", line[2])
+	}
+	_, bestmove := searchWithLimits(unit, limits)
+	if bestmove == "" {
+		t.Errorf("Expecting a best move")
+	}
+
+	unit.NewGame()
+	if unit.EvalTree != nil || unit.ReusedEvalTree != nil {
+		t.Errorf("Expecting the EvalTree to be cleared for a new game")
+	}
+
+	searchWithLimits(unit, limits)
+	other, err := ParseFEN("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.SetPosition(other)
+	if unit.ReusedEvalTree != nil {
+		t.Errorf("Not expecting to reuse the tree for an unrelated position")
+	}
+}
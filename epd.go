@@ -0,0 +1,309 @@
+package chess_engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EPD (Extended Position Description) is the format test suites like WAC
+// and STS are distributed in. A record is the first four fields of a FEN
+// string followed by operations, e.g.
+//
+//	2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";
+type EPD struct {
+	Position *Game
+	// The operations in the order they appeared
+	Operations []*EPDOperation
+}
+
+type EPDOperation struct {
+	Opcode   string
+	Operands []string
+}
+
+func NewEPD(position *Game) *EPD {
+	return &EPD{Position: position}
+}
+
+// Parses an EPD record. The halfmove clock and fullmove number come from
+// the hmvc and fmvn operations if they are there. Some files have them as
+// FEN fields instead, which is supported as well.
+func ParseEPD(line string) (*EPD, error) {
+	fields, rest := splitEPDFields(line, 4)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("Expecting at least four fields in EPD: %s", line)
+	}
+	halfmove, fullmove := "0", "1"
+	if counters, after := splitEPDFields(rest, 2); len(counters) == 2 && isEPDInteger(counters[0]) && isEPDInteger(counters[1]) {
+		halfmove, fullmove = counters[0], counters[1]
+		rest = after
+	}
+	operations, err := parseEPDOperations(rest)
+	if err != nil {
+		return nil, err
+	}
+	epd := &EPD{Operations: operations}
+	if op := epd.GetOperation("hmvc"); op != nil && len(op.Operands) > 0 {
+		halfmove = op.Operands[0]
+	}
+	if op := epd.GetOperation("fmvn"); op != nil && len(op.Operands) > 0 {
+		fullmove = op.Operands[0]
+	}
+	fen := strings.Join(append(fields, halfmove, fullmove), " ")
+	if epd.Position, err = ParseFEN(fen); err != nil {
+		return nil, err
+	}
+	return epd, nil
+}
+
+// Reads all the records in an EPD file. Empty lines and lines starting with
+// a # are skipped.
+func ReadEPD(reader io.Reader) ([]*EPD, error) {
+	result := []*EPD{}
+	scanner := bufio.NewScanner(reader)
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		epd, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("epd: line %d: %s", lineNr, err.Error())
+		}
+		result = append(result, epd)
+	}
+	return result, scanner.Err()
+}
+
+// Splits off the first @n whitespace separated fields.
+func splitEPDFields(line string, n int) ([]string, string) {
+	fields := []string{}
+	rest := strings.TrimLeft(line, " \t")
+	for len(fields) < n && rest != "" {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return fields, rest
+}
+
+func isEPDInteger(str string) bool {
+	_, err := strconv.Atoi(str)
+	return err == nil
+}
+
+// Parses the operations, e.g. `bm Qg6; id "WAC.001";`. Operands can be
+// quoted, in which case they can contain spaces and semicolons.
+func parseEPDOperations(str string) ([]*EPDOperation, error) {
+	result := []*EPDOperation{}
+	current := []string{}
+	endOperation := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		opcode := current[0]
+		if c := opcode[0]; !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') {
+			return fmt.Errorf("Invalid opcode %s", opcode)
+		}
+		result = append(result, &EPDOperation{Opcode: opcode, Operands: current[1:]})
+		current = []string{}
+		return nil
+	}
+	for i := 0; i < len(str); {
+		c := str[i]
+		if c == ';' {
+			if err := endOperation(); err != nil {
+				return nil, err
+			}
+			i++
+		} else if c == ' ' || c == '\t' {
+			i++
+		} else if c == '"' {
+			end := strings.IndexByte(str[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated string in %s", str)
+			}
+			current = append(current, str[i+1:i+1+end])
+			i += end + 2
+		} else {
+			end := strings.IndexAny(str[i:], " \t;")
+			if end < 0 {
+				end = len(str) - i
+			}
+			current = append(current, str[i:i+end])
+			i += end
+		}
+	}
+	// The last semicolon is often missing
+	if err := endOperation(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Returns the first operation with the given opcode, or nil if there isn't
+// one.
+func (e *EPD) GetOperation(opcode string) *EPDOperation {
+	for _, op := range e.Operations {
+		if op.Opcode == opcode {
+			return op
+		}
+	}
+	return nil
+}
+
+// Sets the operands for @opcode, replacing the existing operation if there
+// is one, so that the order is preserved.
+func (e *EPD) SetOperation(opcode string, operands ...string) {
+	if op := e.GetOperation(opcode); op != nil {
+		op.Operands = operands
+		return
+	}
+	e.Operations = append(e.Operations, &EPDOperation{Opcode: opcode, Operands: operands})
+}
+
+// Returns the first operand of @opcode, or an empty string.
+func (e *EPD) GetString(opcode string) string {
+	if op := e.GetOperation(opcode); op != nil && len(op.Operands) > 0 {
+		return op.Operands[0]
+	}
+	return ""
+}
+
+// The name of the position in the test suite.
+func (e *EPD) ID() string {
+	return e.GetString("id")
+}
+
+// The best moves (bm) in the position.
+func (e *EPD) BestMoves() ([]*Move, error) {
+	return e.getMoves("bm")
+}
+
+// The moves to avoid (am) in the position.
+func (e *EPD) AvoidMoves() ([]*Move, error) {
+	return e.getMoves("am")
+}
+
+// The moves of an operation, which are all played from the position.
+func (e *EPD) getMoves(opcode string) ([]*Move, error) {
+	op := e.GetOperation(opcode)
+	if op == nil {
+		return nil, nil
+	}
+	result := []*Move{}
+	for _, operand := range op.Operands {
+		move, err := parseEPDMove(e.Position, operand)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, move)
+	}
+	return result, nil
+}
+
+// The predicted variation (pv), which is a line of moves.
+func (e *EPD) PV() ([]*Move, error) {
+	op := e.GetOperation("pv")
+	if op == nil {
+		return nil, nil
+	}
+	result := []*Move{}
+	game := e.Position
+	for _, operand := range op.Operands {
+		move, err := parseEPDMove(game, operand)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, move)
+		game = game.ApplyMove(move)
+	}
+	return result, nil
+}
+
+// The centipawn evaluation (ce) from the point of view of the side to move.
+// Returns false if there isn't one.
+func (e *EPD) CentipawnEvaluation() (Score, bool, error) {
+	ce := e.GetString("ce")
+	if ce == "" {
+		return 0, false, nil
+	}
+	score, err := strconv.Atoi(ce)
+	if err != nil {
+		return 0, false, fmt.Errorf("Invalid centipawn evaluation %s", ce)
+	}
+	return Score(score), true, nil
+}
+
+// The perft counts from the D1..Dn operations, by depth.
+func (e *EPD) PerftCounts() (map[int]int, error) {
+	result := map[int]int{}
+	for _, op := range e.Operations {
+		if len(op.Opcode) < 2 || op.Opcode[0] != 'D' {
+			continue
+		}
+		depth, err := strconv.Atoi(op.Opcode[1:])
+		if err != nil || depth < 1 {
+			continue
+		}
+		if len(op.Operands) == 0 {
+			return nil, fmt.Errorf("Missing perft count for %s", op.Opcode)
+		}
+		count, err := strconv.Atoi(op.Operands[0])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("Invalid perft count for %s: %s", op.Opcode, op.Operands[0])
+		}
+		result[depth] = count
+	}
+	return result, nil
+}
+
+// Moves in EPD are in SAN, but some suites use long algebraic notation
+// (e.g. e2e4), so we accept both.
+func parseEPDMove(game *Game, str string) (*Move, error) {
+	move, err := ParseSAN(game, str)
+	if err == nil {
+		return move, nil
+	}
+	if long, parseErr := ParseMove(str); parseErr == nil {
+		if valid := game.FindValidMove(long); valid != nil {
+			return valid, nil
+		}
+	}
+	return nil, err
+}
+
+// Returns the record as EPD. The halfmove clock and fullmove number are
+// only written when they are in the operations.
+func (e *EPD) String() string {
+	fields := strings.Fields(e.Position.FENString())
+	result := strings.Join(fields[:4], " ")
+	for _, op := range e.Operations {
+		result += " " + op.Opcode
+		for _, operand := range op.Operands {
+			if isEPDStringOpcode(op.Opcode) || strings.ContainsAny(operand, " \t;") {
+				operand = `"` + operand + `"`
+			}
+			result += " " + operand
+		}
+		result += ";"
+	}
+	return result
+}
+
+// The id and comment (c0..c9) operations take strings, which are always
+// quoted.
+func isEPDStringOpcode(opcode string) bool {
+	if opcode == "id" {
+		return true
+	}
+	return len(opcode) == 2 && opcode[0] == 'c' && opcode[1] >= '0' && opcode[1] <= '9'
+}
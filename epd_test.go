@@ -0,0 +1,142 @@
+package chess_engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ParseEPD(t *testing.T) {
+	unit, err := ParseEPD(`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "Mate; in 3";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Position.FENString() != "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1" {
+		t.Errorf("Unexpected position %s", unit.Position.FENString())
+	}
+	if unit.ID() != "WAC.001" || unit.GetString("c0") != "Mate; in 3" {
+		t.Errorf("Unexpected id '%s' or comment '%s'", unit.ID(), unit.GetString("c0"))
+	}
+	opcodes := []string{}
+	for _, op := range unit.Operations {
+		opcodes = append(opcodes, op.Opcode)
+	}
+	if strings.Join(opcodes, ",") != "bm,id,c0" {
+		t.Errorf("Expecting the operations in order, got %v", opcodes)
+	}
+	moves, err := unit.BestMoves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].String() != "g3g6" {
+		t.Errorf("Expecting g3g6, got %v", moves)
+	}
+	if moves, err = unit.AvoidMoves(); err != nil || moves != nil {
+		t.Errorf("Expecting no moves to avoid, got %v %v", moves, err)
+	}
+}
+
+func Test_ParseEPD_operations(t *testing.T) {
+	unit, err := ParseEPD(`r3k2r/8/8/8/8/8/8/R3K2R w KQkq - am O-O Ra8+; bm O-O-O e1d1; pv O-O-O Ke7 Rd7+; ce -35; hmvc 12; fmvn 30;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Position.HalfmoveClock != 12 || unit.Position.Fullmove != 30 {
+		t.Errorf("Expecting the counters from hmvc and fmvn, got %s", unit.Position.FENString())
+	}
+	moves, err := unit.AvoidMoves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Line(moves).String() != "e1g1 a1a8" {
+		t.Errorf("Unexpected moves to avoid %v", moves)
+	}
+	if moves, err = unit.BestMoves(); err != nil || Line(moves).String() != "e1c1 e1d1" {
+		t.Errorf("Unexpected best moves %v %v", moves, err)
+	}
+	if moves, err = unit.PV(); err != nil || Line(moves).String() != "e1c1 e8e7 d1d7" {
+		t.Errorf("Unexpected pv %v %v", moves, err)
+	}
+	if ce, ok, err := unit.CentipawnEvaluation(); err != nil || !ok || ce != -35 {
+		t.Errorf("Expecting -35, got %d %v %v", ce, ok, err)
+	}
+}
+
+func Test_ParseEPD_perft_counts(t *testing.T) {
+	unit, err := ParseEPD("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 ;D1 20 ;D2 400 ;D3 8902")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, err := unit.PerftCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 3 || counts[1] != 20 || counts[2] != 400 || counts[3] != 8902 {
+		t.Errorf("Unexpected perft counts %v", counts)
+	}
+	unit.SetOperation("D2", "abc")
+	if _, err := unit.PerftCounts(); err == nil {
+		t.Errorf("Expecting an error for an invalid count")
+	}
+}
+
+func Test_EPD_String(t *testing.T) {
+	cases := []string{
+		`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "Mate; in 3";`,
+		`r3k2r/8/8/8/8/8/8/R3K2R w KQkq - am O-O Ra8+; ce -35; hmvc 12; fmvn 30;`,
+		`8/8/8/8/8/8/8/k6K b - -`,
+	}
+	for _, epd := range cases {
+		unit, err := ParseEPD(epd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.String() != epd {
+			t.Errorf("Expecting '%s', got '%s'", epd, unit.String())
+		}
+	}
+	position, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewEPD(position)
+	unit.SetOperation("id", "start")
+	unit.SetOperation("bm", "e4", "d4")
+	unit.SetOperation("id", "startpos")
+	expected := `rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - id "startpos"; bm e4 d4;`
+	if unit.String() != expected {
+		t.Errorf("Expecting '%s', got '%s'", expected, unit.String())
+	}
+}
+
+func Test_ReadEPD(t *testing.T) {
+	file := `# Win at chess
+2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";
+
+8/7p/5k2/5p2/p1p2P2/Pr1pPK2/1P1R3P/8 b - - bm Rxb2; id "WAC.002";
+`
+	epds, err := ReadEPD(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(epds) != 2 || epds[1].ID() != "WAC.002" {
+		t.Fatalf("Expecting two records, got %v", epds)
+	}
+	if _, err := ReadEPD(strings.NewReader(file + "invalid\n")); err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("Expecting an error on line 5, got %v", err)
+	}
+}
+
+func Test_ParseEPD_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"8/8/8/8 w",
+		"8/8/8/8/8/8/8/k6K w - - id \"unterminated;",
+		"8/8/8/8/8/8/8/k6K w - - 12abc;",
+		"8/8/8/8/8/8/8/k6K x - -",
+	}
+	for _, epd := range cases {
+		if _, err := ParseEPD(epd); err == nil {
+			t.Errorf("Expecting an error for '%s'", epd)
+		}
+	}
+}
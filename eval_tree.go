@@ -1,5 +1,9 @@
 package chess_engine
 
+import (
+	"sort"
+)
+
 type EvalResult struct {
 	Score
 	Line []*Move
@@ -131,6 +135,33 @@ func (t *EvalTree) GetBestLine() *EvalResult {
 	return NewEvalResult(bestLine, tree.Score)
 }
 
+// Returns the best lines for the @n best replies, best first. The scores are
+// from the perspective of the side to move at the root of the tree.
+func (t *EvalTree) GetBestLines(n int) []*EvalResult {
+	replies := make([]*EvalTree, 0, len(t.Replies))
+	for _, reply := range t.Replies {
+		replies = append(replies, reply)
+	}
+	sort.Slice(replies, func(i, j int) bool {
+		if replies[i].Score != replies[j].Score {
+			return replies[i].Score > replies[j].Score
+		}
+		// Make sure the order matches BestLine and is stable otherwise
+		if replies[i] == t.BestLine || replies[j] == t.BestLine {
+			return replies[i] == t.BestLine
+		}
+		return replies[i].Move.String() < replies[j].Move.String()
+	})
+	if len(replies) > n {
+		replies = replies[:n]
+	}
+	result := make([]*EvalResult, len(replies))
+	for i, reply := range replies {
+		result[i] = NewEvalResult(reply.GetBestLine().Line, reply.Score)
+	}
+	return result
+}
+
 func (t *EvalTree) Prune() {
 	if t.BestLine == nil {
 		return
@@ -158,6 +189,30 @@ func (t *EvalTree) Prune() {
 	}
 }
 
+// Makes this tree the root of a new tree, after @plies moves have been played
+// from the root of the old tree. Mate scores are relative to the root, so
+// they are moved closer.
+func (t *EvalTree) Detach(plies int) *EvalTree {
+	t.Parent = nil
+	t.Move = nil
+	t.adjustMateScores(Score(plies))
+	if len(t.Replies) > 0 {
+		t.UpdateBestLine()
+	}
+	return t
+}
+
+func (t *EvalTree) adjustMateScores(plies Score) {
+	if t.Score >= Mate-maxMatePlies && t.Score <= Mate {
+		t.Score += plies
+	} else if t.Score <= OpponentMate+maxMatePlies && t.Score >= OpponentMate {
+		t.Score -= plies
+	}
+	for _, reply := range t.Replies {
+		reply.adjustMateScores(plies)
+	}
+}
+
 func (t *EvalTree) MaxDepth() int {
 	depth := 0
 	for _, reply := range t.Replies {
@@ -107,3 +107,53 @@ func Test_EvalTree_prune(t *testing.T) {
 	}
 	unit.Insert([]*Move{m1, m3, m2}, 1.0)
 }
+
+func Test_EvalTree_GetBestLines(t *testing.T) {
+	unit := NewEvalTree(nil)
+	m1 := NewMove(A2, A3)
+	m2 := NewMove(E2, E4)
+	m3 := NewMove(D2, D4)
+	m4 := NewMove(E7, E5)
+	unit.Insert([]*Move{m1}, 100)
+	unit.Insert([]*Move{m2}, 150)
+	unit.Insert([]*Move{m2, m4}, -200)
+	unit.Insert([]*Move{m3}, 50)
+
+	lines := unit.GetBestLines(2)
+	if len(lines) != 2 {
+		t.Fatalf("Expecting 2 lines, got %d", len(lines))
+	}
+	if len(lines[0].Line) != 2 || lines[0].Line[0] != m2 || lines[0].Line[1] != m4 || lines[0].Score != 200 {
+		t.Errorf("Expecting e2e4 e7e5 with score 200, got %v %d", lines[0].Line, lines[0].Score)
+	}
+	if len(lines[1].Line) != 1 || lines[1].Line[0] != m1 || lines[1].Score != 100 {
+		t.Errorf("Expecting a2a3 with score 100, got %v %d", lines[1].Line, lines[1].Score)
+	}
+	if len(unit.GetBestLines(10)) != 3 {
+		t.Errorf("Expecting a line for every reply")
+	}
+}
+
+func Test_EvalTree_Detach(t *testing.T) {
+	unit := NewEvalTree(nil)
+	m1 := NewMove(E2, E4)
+	m2 := NewMove(E7, E5)
+	m3 := NewMove(D1, H5)
+	m4 := NewMove(G1, F3)
+	unit.Insert([]*Move{m1, m2, m3}, Mate-3)
+	unit.Insert([]*Move{m1, m2, m4}, 50)
+
+	subtree := unit.Traverse([]*Move{m1, m2}).Detach(2)
+	if subtree.Parent != nil || subtree.Move != nil {
+		t.Errorf("Expecting the subtree to be detached")
+	}
+	if subtree.Score != Mate-1 {
+		t.Errorf("Expecting mate in one, got %d", subtree.Score)
+	}
+	if subtree.BestLine == nil || subtree.BestLine.Move != m3 {
+		t.Errorf("Expecting best move %s", m3)
+	}
+	if subtree.Replies[m4.String()].Score != 50 {
+		t.Errorf("Expecting normal scores to stay the same, got %d", subtree.Replies[m4.String()].Score)
+	}
+}
@@ -3,22 +3,26 @@ package chess_engine
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 )
 
 type Evaluator func(fen *Game, phase int) Score
 
 type Evaluators []Evaluator
 
+// The material value of each piece in centipawns.
+var MaterialScores = map[NormalizedPiece]int{
+	Pawn:   100,
+	Knight: 325,
+	Bishop: 325,
+	King:   400,
+	Rook:   550,
+	Queen:  1100,
+}
+
 func NaiveMaterialEvaluator(f *Game, phase int) Score {
 	score := 0
-	materialScore := map[NormalizedPiece]int{
-		Pawn:   100,
-		Knight: 325,
-		Bishop: 325,
-		King:   400,
-		Rook:   550,
-		Queen:  1100,
-	}
+	materialScore := MaterialScores
 	for pieceIx, positions := range f.Pieces[White] {
 		piece := NormalizedPiece(pieceIx)
 		score += positions.Count() * materialScore[piece]
@@ -204,6 +208,45 @@ func RandomEvaluator(f *Game) Score {
 	return Score(rand.NormFloat64())
 }
 
+type NamedEvaluator struct {
+	Name      string
+	Evaluator Evaluator
+}
+
+// The evaluators that can be selected by name, e.g. on the command line or
+// with the Evaluators UCI option.
+var NamedEvaluators = []NamedEvaluator{
+	{"naive-material", NaiveMaterialEvaluator},
+	{"space", SpaceEvaluator},
+	{"tempo", TempoEvaluator},
+	{"mobility", MobilityEvaluator},
+	{"pawn-structure", PawnStructureEvaluator},
+}
+
+// Parses a comma or space separated list of evaluator names, e.g.
+// "naive-material,space".
+func ParseEvaluators(names string) (Evaluators, error) {
+	result := Evaluators{}
+	for _, name := range splitEvaluatorNames(names) {
+		found := false
+		for _, named := range NamedEvaluators {
+			if strings.EqualFold(named.Name, name) {
+				result = append(result, named.Evaluator)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown evaluator %s", name)
+		}
+	}
+	return result, nil
+}
+
+func splitEvaluatorNames(names string) []string {
+	return strings.FieldsFunc(names, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
 func (e Evaluators) Eval(position *Game) (Score, bool) {
 	if position.Score != nil {
 		return *position.Score, false
@@ -286,12 +329,12 @@ func (e Evaluators) Debug(position *Game) {
 	}
 }
 
-func (e Evaluators) GetAlternativeMove(position *Game, seen map[string]bool) (*Game, int) {
+func (e Evaluators) GetAlternativeMove(position *Game, seen SeenMap) (*Game, int) {
 	nextBest := LowestScore
 	nodes := 0
 	var nextBestGame *Game
 	for _, game := range position.NextGames() {
-		if _, ok := seen[game.FENString()]; !ok {
+		if !seen.Seen(game) {
 			score, new := e.Eval(game)
 			if new {
 				nodes++
@@ -305,7 +348,7 @@ func (e Evaluators) GetAlternativeMove(position *Game, seen map[string]bool) (*G
 	return nextBestGame, nodes
 }
 
-func (e Evaluators) GetAlternativeMoveInLine(position *Game, line []*Move, seen map[string]bool) (*Game, int) {
+func (e Evaluators) GetAlternativeMoveInLine(position *Game, line []*Move, seen SeenMap) (*Game, int) {
 	for _, m := range line {
 		position = position.ApplyMove(m)
 	}
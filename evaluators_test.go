@@ -160,17 +160,17 @@ func Test_Eval_AlternativeMove_space_evaluator(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	fenMap := map[string]bool{}
-	fenMap[fen] = true
+	seen := NewSeenMap()
+	seen.Set(position)
 
-	game, _ := unit.GetAlternativeMove(position, fenMap)
+	game, _ := unit.GetAlternativeMove(position, seen)
 	if game.Line[0].String() != "e2e4" {
 		t.Errorf("Expecting e2e4 as opening move for space evaluator, got %s", game.Line)
 	}
 
-	fenMap[game.FENString()] = true
+	seen.Set(game)
 
-	game, _ = unit.GetAlternativeMove(position, fenMap)
+	game, _ = unit.GetAlternativeMove(position, seen)
 	if game.Line[0].String() != "d2d4" {
 		t.Errorf("Expecting d2d4 as an alternative opening move for space evaluator, got %s", game.Line)
 	}
@@ -184,17 +184,17 @@ func Test_Eval_AlternativeMove_space_evaluator_black(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	fenMap := map[string]bool{}
-	fenMap[fen] = true
+	seen := NewSeenMap()
+	seen.Set(position)
 
-	game, _ := unit.GetAlternativeMove(position, fenMap)
+	game, _ := unit.GetAlternativeMove(position, seen)
 	if game.Line[0].String() != "e7e5" {
 		t.Errorf("Expecting e7e5 as opening move for space evaluator, got %s", game.Line)
 	}
 
-	fenMap[game.FENString()] = true
+	seen.Set(game)
 
-	game, _ = unit.GetAlternativeMove(position, fenMap)
+	game, _ = unit.GetAlternativeMove(position, seen)
 	if game.Line[0].String() != "d7d5" {
 		t.Errorf("Expecting d7d5 as an alternative opening move for space evaluator, got %s", game.Line)
 	}
@@ -0,0 +1,185 @@
+package chess_engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// How long we wait for an external engine to answer uci and isready.
+const ExternalEngineTimeout = 10 * time.Second
+
+// ExternalEngine runs a UCI engine as a subprocess, so that it can be used
+// wherever we would use one of our own engines, e.g. to compare them on a
+// test suite. The process is started on first use.
+type ExternalEngine struct {
+	Path string
+	Args []string
+
+	position *Game
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	// The lines the engine writes; closed when the process exits.
+	lines chan string
+}
+
+func NewExternalEngine(path string, args ...string) *ExternalEngine {
+	return &ExternalEngine{
+		Path: path,
+		Args: args,
+	}
+}
+
+func (e *ExternalEngine) ensureStarted() error {
+	if e.cmd != nil {
+		return nil
+	}
+	cmd := exec.Command(e.Path, e.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	e.cmd = cmd
+	e.stdin = stdin
+	e.lines = make(chan string, 100)
+	go readLines(bufio.NewReader(stdout), e.lines)
+
+	e.send("uci")
+	err = e.waitFor("uciok")
+	if err == nil {
+		err = e.isReady()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		e.cmd = nil
+	}
+	return err
+}
+
+func (e *ExternalEngine) send(line string) {
+	fmt.Fprintln(e.stdin, line)
+}
+
+func (e *ExternalEngine) isReady() error {
+	e.send("isready")
+	return e.waitFor("readyok")
+}
+
+// Reads lines until the engine sends @expected.
+func (e *ExternalEngine) waitFor(expected string) error {
+	timeout := time.After(ExternalEngineTimeout)
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return fmt.Errorf("%s exited while waiting for %s", e.Path, expected)
+			}
+			if line == expected {
+				return nil
+			}
+		case <-timeout:
+			return fmt.Errorf("%s didn't send %s", e.Path, expected)
+		}
+	}
+}
+
+func (e *ExternalEngine) GetPosition() *Game {
+	return e.position
+}
+
+func (e *ExternalEngine) SetPosition(game *Game) {
+	e.position = game
+}
+
+// The external engine evaluates positions itself.
+func (e *ExternalEngine) AddEvaluator(Evaluator) {}
+
+// Passes the options on to the engine using the standard UCI option names.
+func (e *ExternalEngine) SetOption(opt EngineOption, val OptionValue) error {
+	names := map[EngineOption]string{
+		HASH:    "Hash",
+		THREADS: "Threads",
+		MULTIPV: "MultiPV",
+	}
+	name, ok := names[opt]
+	if !ok {
+		return fmt.Errorf("Option not supported by external engine %s", e.Path)
+	}
+	if err := e.ensureStarted(); err != nil {
+		return err
+	}
+	e.send(fmt.Sprintf("setoption name %s value %d", name, val.Int))
+	return e.isReady()
+}
+
+func (e *ExternalEngine) NewGame() {
+	if err := e.ensureStarted(); err != nil {
+		return
+	}
+	e.send("ucinewgame")
+	e.isReady()
+}
+
+// Starts the search and passes everything the engine says on to
+// @engineOutput, up to and including the best move.
+func (e *ExternalEngine) Start(engineOutput chan string, limits *SearchLimits) {
+	if err := e.ensureStarted(); err != nil {
+		engineOutput <- "info string error: " + err.Error()
+		engineOutput <- "bestmove (none)"
+		return
+	}
+	if limits == nil {
+		limits = NewSearchLimits()
+	}
+	args := ""
+	if limits.String() != "none" {
+		args = " " + limits.String()
+	}
+	e.send("position fen " + e.position.FENString())
+	e.send("go" + args)
+	go func() {
+		for line := range e.lines {
+			engineOutput <- line
+			if strings.HasPrefix(line, "bestmove") {
+				return
+			}
+		}
+		engineOutput <- "info string error: " + e.Path + " exited"
+		engineOutput <- "bestmove (none)"
+	}()
+}
+
+func (e *ExternalEngine) PonderHit() {
+	if e.cmd != nil {
+		e.send("ponderhit")
+	}
+}
+
+func (e *ExternalEngine) Stop() {
+	if e.cmd != nil {
+		e.send("stop")
+	}
+}
+
+// Tells the engine to quit and waits for the process to exit.
+func (e *ExternalEngine) Close() error {
+	if e.cmd == nil {
+		return nil
+	}
+	e.send("quit")
+	e.stdin.Close()
+	err := e.cmd.Wait()
+	e.cmd = nil
+	return err
+}
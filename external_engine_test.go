@@ -0,0 +1,63 @@
+package chess_engine
+
+import (
+	"bufio"
+	"os"
+	"testing"
+)
+
+// Not a real test: this runs the UCI loop when the test binary is started
+// as an external engine by the tests below.
+func Test_ExternalEngine_helper_process(t *testing.T) {
+	if os.Getenv("BS_ENGINE_HELPER_PROCESS") != "1" {
+		return
+	}
+	engine := NewAlphaBetaEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	uci := NewUCI("helper", "test", engine)
+	uci.LogFile = ""
+	uci.Start(bufio.NewReader(os.Stdin))
+	os.Exit(0)
+}
+
+func Test_ExternalEngine(t *testing.T) {
+	t.Setenv("BS_ENGINE_HELPER_PROCESS", "1")
+	unit := NewExternalEngine(os.Args[0], "-test.run=^Test_ExternalEngine_helper_process$")
+	defer unit.Close()
+	if err := unit.SetOption(HASH, IntValue(32)); err != nil {
+		t.Fatal(err)
+	}
+	if err := unit.SetOption(EVALUATORS, StringValue("space")); err == nil {
+		t.Errorf("Expecting an error for an option the engine doesn't have")
+	}
+	unit.NewGame()
+	fen, err := ParseFEN("7k/8/6K1/8/8/8/8/5Q2 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.SetPosition(fen)
+	limits := NewSearchLimits()
+	limits.Depth = 2
+	infos, bestMove := searchWithLimits(unit, limits)
+	if len(infos) == 0 {
+		t.Errorf("Expecting info lines to be passed on")
+	}
+	if bestMove != "f1f8" {
+		t.Errorf("Expecting a mate, got %s", bestMove)
+	}
+	if err := unit.Close(); err != nil {
+		t.Errorf("Expecting the engine to quit cleanly, got %s", err)
+	}
+}
+
+func Test_ExternalEngine_reports_errors(t *testing.T) {
+	unit := NewExternalEngine("/nonexistent/engine")
+	fen, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit.SetPosition(fen)
+	if _, bestMove := searchWithLimits(unit, NewSearchLimits()); bestMove != "(none)" {
+		t.Errorf("Expecting no move, got %s", bestMove)
+	}
+}
@@ -28,6 +28,10 @@ type Game struct {
 	HalfmoveClock       int
 	Fullmove            int
 
+	// The Zobrist hash of the position (see zobrist.go), which is
+	// incrementally updated in ApplyMove.
+	Hash uint64
+
 	// The line we're currently pondering on
 	Line []*Move
 
@@ -43,6 +47,8 @@ type Game struct {
 	nextGames []*Game
 }
 
+const StartingPositionFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
 func ParseFEN(fenstr string) (*Game, error) {
 	fen := Game{}
 	forStr := ""
@@ -105,6 +111,7 @@ func ParseFEN(fenstr string) (*Game, error) {
 	}
 	fen.SquareControl = NewSquareControlFromBoard(fen.Board)
 	fen.validMoves = NewValidMovesListFromBoard(fen.Board)
+	fen.Hash = fen.ComputeHash()
 	return &fen, nil
 }
 
@@ -127,16 +134,106 @@ func (f *Game) IsDraw() bool {
 	if f.HalfmoveClock >= 100 {
 		return true
 	}
+	if f.IsThreefoldRepetition() {
+		return true
+	}
+	if f.IsInsufficientMaterial() {
+		return true
+	}
 	checks := f.GetChecks()
 	if len(checks) > 0 {
 		return false
 	}
-	// TODO: draw by repetition
-	// TODO: draw by insufficient material
 	// Stalemate
 	return len(f.ValidMoves()) == 0
 }
 
+func (f *Game) IsInsufficientMaterial() bool {
+	return f.Pieces.IsInsufficientMaterial()
+}
+
+// Returns the number of times the current position has occurred in this
+// game, including the current occurrence. We only have to walk back
+// HalfmoveClock plies, because captures and pawn moves are irreversible.
+func (f *Game) RepetitionCount() int {
+	count := 1
+	game := f
+	for ply := 1; ply <= f.HalfmoveClock; ply++ {
+		game = game.Parent
+		if game == nil {
+			break
+		}
+		// The same side needs to be on move, so only every other ply can
+		// be a repetition.
+		if ply%2 == 0 && f.IsSamePosition(game) {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *Game) IsThreefoldRepetition() bool {
+	return f.RepetitionCount() >= 3
+}
+
+func (f *Game) IsFivefoldRepetition() bool {
+	return f.RepetitionCount() >= 5
+}
+
+// Two positions are the same if the same pieces are on the same squares,
+// the same side is to move and the castling and en passant rights are the
+// same, which is exactly what the Zobrist hash captures. The move counters
+// are not taken into account.
+func (f *Game) IsSamePosition(other *Game) bool {
+	return f.Hash == other.Hash
+}
+
+// Returns whether the move captures a piece, including en passant captures.
+func (f *Game) IsCapture(move *Move) bool {
+	if f.Board[move.To] != NoPiece {
+		return true
+	}
+	return move.GetEnPassantCapture(f.Board[move.From], f.EnPassantVulnerable) != nil
+}
+
+// Returns the valid move that matches @move, or nil if @move isn't valid in
+// this position. The colour of the promotion piece is ignored, because UCI
+// always uses lowercase letters for promotions.
+func (f *Game) FindValidMove(move *Move) *Move {
+	for _, valid := range f.ValidMoves() {
+		if valid.From != move.From || valid.To != move.To {
+			continue
+		}
+		if valid.Promote.ToNormalizedPiece() == move.Promote.ToNormalizedPiece() {
+			return valid
+		}
+	}
+	return nil
+}
+
+// Applies a list of moves in long algebraic notation (e.g. "e2e4 e7e5"),
+// making sure that every move is valid. The resulting Game still has the
+// positions leading up to it as its Parents, so that we can detect
+// repetitions, but it starts with an empty Line.
+func (f *Game) ApplyMoves(moves []string) (*Game, error) {
+	game := f
+	for _, moveStr := range moves {
+		move, err := ParseMove(moveStr)
+		if err != nil {
+			return nil, err
+		}
+		valid := game.FindValidMove(move)
+		if valid == nil {
+			return nil, fmt.Errorf("Invalid move %s in position %s", moveStr, game.FENString())
+		}
+		game = game.ApplyMove(valid)
+	}
+	if game != f {
+		game.Line = []*Move{}
+	}
+	return game, nil
+}
+
 func (f *Game) GetChecks() []*Move {
 	return f.validMoves.GetChecks(f.ToMove, f.Pieces)
 }
@@ -378,6 +475,11 @@ func (f *Game) ApplyMove(move *Move) *Game {
 		board[move.To] = move.Promote
 	}
 
+	hash := f.Hash ^ zobristPiece(movingPiece, move.From) ^ zobristPiece(board[move.To], move.To)
+	if f.Board[move.To] != NoPiece {
+		hash ^= zobristPiece(f.Board[move.To], move.To)
+	}
+
 	// Handle castles and en-passant
 	castles := move.GetRookCastlesMove(movingPiece)
 	if castles != nil {
@@ -388,6 +490,7 @@ func (f *Game) ApplyMove(move *Move) *Game {
 			panic("Illegal castles, no rook found")
 		}
 		board.ApplyMove(castles.From, castles.To)
+		hash ^= zobristPiece(board[castles.To], castles.From) ^ zobristPiece(board[castles.To], castles.To)
 	}
 	enpassant := NoPosition
 	switch movingPiece {
@@ -428,6 +531,7 @@ func (f *Game) ApplyMove(move *Move) *Game {
 	enpassantCapture := move.GetEnPassantCapture(movingPiece, f.EnPassantVulnerable)
 	if enpassantCapture != nil {
 		result.Pieces.RemovePosition(Pawn.ToPiece(f.ToMove.Opposite()), *enpassantCapture)
+		hash ^= zobristPiece(Pawn.ToPiece(f.ToMove.Opposite()), *enpassantCapture)
 	}
 
 	result.SquareControl = f.SquareControl.ApplyMove(move, movingPiece, f.Board[move.To], board, f.EnPassantVulnerable)
@@ -449,6 +553,11 @@ func (f *Game) ApplyMove(move *Move) *Game {
 	result.Line = line
 	result.Parent = f
 
+	hash ^= zobristBlackToMove
+	hash ^= zobristCastleStatuses(f.CastleStatuses) ^ zobristCastleStatuses(result.CastleStatuses)
+	hash ^= zobristEnPassantSquare(f.EnPassantVulnerable) ^ zobristEnPassantSquare(enpassant)
+	result.Hash = hash
+
 	result.validMoves = f.validMoves.ApplyMove(move, movingPiece, board, f.EnPassantVulnerable, result.Pieces)
 
 	return result
@@ -2,6 +2,8 @@ package chess_engine
 
 import (
 	"fmt"
+	"os"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -482,6 +484,16 @@ func Test_ApplyMove_game(t *testing.T) {
 			if unit.FENString() != move[0] {
 				t.Errorf("Expecting FEN %s got %s", move[0], unit.FENString())
 			}
+			if unit.Hash != unit.ComputeHash() {
+				t.Errorf("Expecting incremental hash %x to equal computed hash %x in %s", unit.Hash, unit.ComputeHash(), move[0])
+			}
+			parsed, err := ParseFEN(move[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unit.Hash != parsed.Hash {
+				t.Errorf("Expecting incremental hash %x to equal parsed hash %x in %s", unit.Hash, parsed.Hash, move[0])
+			}
 			m, err := ParseMove(move[1])
 			if err != nil {
 				t.Fatal(err)
@@ -512,6 +524,86 @@ func Test_ApplyMove_game(t *testing.T) {
 	}
 }
 
+func Test_IsThreefoldRepetition(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shuffle := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+	for i := 0; i < 4; i++ {
+		for j, moveStr := range shuffle {
+			if i == 1 && j == 3 {
+				if unit.IsThreefoldRepetition() {
+					t.Errorf("Position has only been repeated twice in %s", Line(unit.Line))
+				}
+			}
+			unit = unit.ApplyMove(MustParseMove(moveStr))
+		}
+		if i == 1 {
+			if !unit.IsThreefoldRepetition() || !unit.IsDraw() {
+				t.Errorf("Expecting draw by threefold repetition in %s", Line(unit.Line))
+			}
+			if unit.IsFivefoldRepetition() {
+				t.Errorf("Position has only been repeated three times in %s", Line(unit.Line))
+			}
+		}
+	}
+	if unit.RepetitionCount() != 5 {
+		t.Errorf("Expecting 5 repetitions, got %d", unit.RepetitionCount())
+	}
+	if !unit.IsFivefoldRepetition() {
+		t.Errorf("Expecting draw by fivefold repetition in %s", Line(unit.Line))
+	}
+}
+
+func Test_IsThreefoldRepetition_irreversible_move(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, moveStr := range []string{"g1f3", "g8f6", "f3g1", "f6g8", "e2e4", "e7e5", "g1f3", "g8f6", "f3g1", "f6g8"} {
+		unit = unit.ApplyMove(MustParseMove(moveStr))
+	}
+	if unit.RepetitionCount() != 2 {
+		t.Errorf("Expecting 2 repetitions since the last pawn move, got %d", unit.RepetitionCount())
+	}
+	if unit.IsDraw() {
+		t.Errorf("Not expecting a draw in %s", Line(unit.Line))
+	}
+}
+
+func Test_IsInsufficientMaterial(t *testing.T) {
+	cases := []struct {
+		FEN      string
+		Expected bool
+	}{
+		{"k7/8/8/8/8/8/8/K7 w - - 0 1", true},
+		{"k7/8/8/8/8/8/8/KB6 w - - 0 1", true},
+		{"k7/8/8/8/8/8/8/KN6 w - - 0 1", true},
+		{"kn6/8/8/8/8/8/8/K7 b - - 0 1", true},
+		{"k1b5/8/8/8/8/8/8/KB6 w - - 0 1", true},
+		{"kb6/8/8/8/8/8/8/KB6 w - - 0 1", false},
+		{"kn6/8/8/8/8/8/8/KN6 w - - 0 1", false},
+		{"kn6/8/8/8/8/8/8/KB6 w - - 0 1", false},
+		{"k7/8/8/8/8/8/8/KNN5 w - - 0 1", false},
+		{"k7/8/8/8/8/8/P7/K7 w - - 0 1", false},
+		{"k7/8/8/8/8/8/8/KR6 w - - 0 1", false},
+		{"k7/8/8/8/8/8/8/KQ6 w - - 0 1", false},
+	}
+	for _, c := range cases {
+		unit, err := ParseFEN(c.FEN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.IsInsufficientMaterial() != c.Expected {
+			t.Errorf("Expecting insufficient material to be %v in %s", c.Expected, c.FEN)
+		}
+		if unit.IsDraw() != c.Expected {
+			t.Errorf("Expecting draw to be %v in %s", c.Expected, c.FEN)
+		}
+	}
+}
+
 func runPerftTests(t *testing.T, fenStr string, nodes, checks []int) {
 
 	game, err := ParseFEN(fenStr)
@@ -519,11 +611,16 @@ func runPerftTests(t *testing.T, fenStr string, nodes, checks []int) {
 		panic(err)
 	}
 	for depth, expectedNodes := range nodes {
-		gotNodes, gotChecks := Perft(game, depth+1)
+		// The nodes are counted in parallel, so that the deep trees are
+		// checked for races too, and the checks sequentially.
+		gotNodes := PerftParallel(game, depth+1, runtime.NumCPU(), nil).Nodes
 		if gotNodes != expectedNodes {
 			t.Errorf("Expecting %d moves at depth %d for %s, got %d (diff %d)", expectedNodes, depth+1, fenStr, gotNodes, gotNodes-expectedNodes)
 		}
-		if checks != nil && gotChecks != checks[depth] {
+		if checks == nil {
+			continue
+		}
+		if gotChecks := PerftWithHash(game, depth+1, nil).Checks; gotChecks != checks[depth] {
 			t.Errorf("Expecting %d checks at depth %d for %s, got %d", checks[depth], depth+1, fenStr, gotChecks)
 		}
 	}
@@ -584,6 +681,182 @@ func Test_Perft6(t *testing.T) {
 	runPerftTests(t, "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", perft, nil)
 }
 
+func Test_PerftWithHash_counts(t *testing.T) {
+	cases := []struct {
+		fen      string
+		depth    int
+		expected PerftResult
+	}{
+		{StartingPositionFEN, 3, PerftResult{Nodes: 8902, Captures: 34, Checks: 12}},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 2,
+			PerftResult{Nodes: 2039, Captures: 351, EnPassants: 1, Castles: 91, Checks: 3}},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 3,
+			PerftResult{Nodes: 2812, Captures: 209, EnPassants: 2, Checks: 267}},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 2,
+			PerftResult{Nodes: 264, Captures: 87, Castles: 6, Promotions: 48, Checks: 10}},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3,
+			PerftResult{Nodes: 9467, Captures: 1021, EnPassants: 4, Promotions: 120, Checks: 38, Mates: 22}},
+	}
+	for _, c := range cases {
+		game, err := ParseFEN(c.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := PerftWithHash(game, c.depth, nil)
+		if result != c.expected {
+			t.Errorf("Expecting %+v at depth %d for %s, got %+v", c.expected, c.depth, c.fen, result)
+		}
+		hashed := PerftWithHash(game, c.depth, NewPerftHashTable(1))
+		if hashed != c.expected {
+			t.Errorf("Expecting %+v at depth %d for %s with a hash table, got %+v", c.expected, c.depth, c.fen, hashed)
+		}
+	}
+}
+
+func Test_PerftDivide(t *testing.T) {
+	game, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	divisions := PerftDivide(game, 3, nil)
+	if len(divisions) != 48 {
+		t.Fatalf("Expecting 48 root moves, got %d", len(divisions))
+	}
+	total := PerftResult{}
+	for i, division := range divisions {
+		if i > 0 && divisions[i-1].Move.String() >= division.Move.String() {
+			t.Errorf("Expecting the moves to be sorted, got %s before %s", divisions[i-1].Move, division.Move)
+		}
+		total.Add(division.PerftResult)
+	}
+	if total != PerftWithHash(game, 3, nil) {
+		t.Errorf("Expecting the divisions to add up to the perft result, got %+v", total)
+	}
+	for _, division := range divisions {
+		if division.Move.String() == "e1g1" && division.Nodes != 2059 {
+			t.Errorf("Expecting 2059 nodes after e1g1, got %d", division.Nodes)
+		}
+	}
+	if len(PerftDivide(game, 0, nil)) != 0 {
+		t.Errorf("Expecting no divisions at depth 0")
+	}
+}
+
+func Test_PerftParallel(t *testing.T) {
+	game, err := ParseFEN("r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PerftWithHash(game, 3, nil)
+	for _, workers := range []int{1, 2, 4, 16} {
+		if result := PerftParallel(game, 3, workers, nil); result != expected {
+			t.Errorf("Expecting %+v with %d workers, got %+v", expected, workers, result)
+		}
+		if result := PerftParallel(game, 3, workers, NewPerftHashTable(1)); result != expected {
+			t.Errorf("Expecting %+v with %d workers and a hash table, got %+v", expected, workers, result)
+		}
+	}
+	// Every root move is a leaf at depth 1, so the divisions have to
+	// classify them as well.
+	leaves := PerftParallel(game, 1, 4, nil)
+	if leaves != PerftWithHash(game, 1, nil) {
+		t.Errorf("Expecting the same counts at depth 1, got %+v", leaves)
+	}
+	divisions := PerftDivideParallel(game, 2, 4, nil)
+	sequential := PerftDivide(game, 2, nil)
+	for i, division := range divisions {
+		if *division.Move != *sequential[i].Move || division.PerftResult != sequential[i].PerftResult {
+			t.Errorf("Expecting %s %+v, got %s %+v", sequential[i].Move, sequential[i].PerftResult, division.Move, division.PerftResult)
+		}
+	}
+	if PerftParallel(game, 0, 4, nil).Nodes != 1 {
+		t.Errorf("Expecting one node at depth 0")
+	}
+}
+
+func Test_Perft_suite(t *testing.T) {
+	file, err := os.Open("testdata/perftsuite.epd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	suite, err := ReadEPD(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxDepth := 2
+	if os.Getenv("INTEGRATION") == "1" {
+		maxDepth = 4
+	}
+	hash := NewPerftHashTable(16)
+	for _, epd := range suite {
+		counts, err := epd.PerftCounts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for depth := 1; depth <= maxDepth; depth++ {
+			result := PerftParallel(epd.Position, depth, 4, hash)
+			if result.Nodes != counts[depth] {
+				t.Errorf("Expecting %d nodes at depth %d for %s, got %d", counts[depth], depth, epd.ID(), result.Nodes)
+			}
+		}
+	}
+}
+
+func Test_ApplyMoves(t *testing.T) {
+	unit, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := unit.ApplyMoves([]string{"e2e4", "e7e5", "g1f3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"
+	if game.FENString() != expected {
+		t.Errorf("Expecting %s, got %s", expected, game.FENString())
+	}
+	if len(game.Line) != 0 {
+		t.Errorf("Expecting an empty line, got %v", game.Line)
+	}
+	if game.Parent == nil || game.Parent.Parent == nil || game.Parent.Parent.Parent != unit {
+		t.Errorf("Expecting the game to keep its history")
+	}
+	for _, moves := range [][]string{{"e2e5"}, {"e2e4", "e2e4"}, {"e2"}} {
+		if _, err := unit.ApplyMoves(moves); err == nil {
+			t.Errorf("Expecting an error for %v", moves)
+		}
+	}
+}
+
+func Test_ApplyMoves_promotion(t *testing.T) {
+	unit, err := ParseFEN("8/4P3/8/8/8/8/8/k6K w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := unit.ApplyMoves([]string{"e7e8n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Board[E8] != WhiteKnight {
+		t.Errorf("Expecting a white knight on e8, got %v", game.Board[E8])
+	}
+}
+
+func Test_ApplyMoves_repetition(t *testing.T) {
+	unit, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := unit.ApplyMoves(strings.Split("g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1 f6g8", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !game.IsThreefoldRepetition() || !game.IsDraw() {
+		t.Errorf("Expecting a draw by repetition")
+	}
+}
+
 func Benchmark_ApplyMove(t *testing.B) {
 	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
 	if err != nil {
@@ -0,0 +1,78 @@
+package chess_engine
+
+// Killer moves are kept for this many plies from the root.
+const MaxKillerPly = 128
+
+// History scores are halved when one of them gets bigger than this, so that
+// recent cutoffs count more than old ones.
+const maxHistoryScore = 1 << 16
+
+// The history heuristic and killer moves remember quiet moves that caused a
+// beta cutoff, so that we can try them early in other positions: a move that
+// refuted one line often refutes its siblings as well.
+type HistoryTable struct {
+	// The last two quiet moves that caused a cutoff at every ply
+	Killers [MaxKillerPly][2]*Move
+	// How often a move from one square to another caused a cutoff, weighted
+	// by the depth of the search
+	History [64][64]int
+}
+
+func NewHistoryTable() *HistoryTable {
+	return &HistoryTable{}
+}
+
+// Records that the quiet @move caused a beta cutoff at @ply, in a search of
+// @depth.
+func (h *HistoryTable) AddCutoff(move *Move, ply, depth int) {
+	if ply < MaxKillerPly {
+		killers := &h.Killers[ply]
+		if killers[0] == nil || *killers[0] != *move {
+			killers[1] = killers[0]
+			killers[0] = move
+		}
+	}
+	h.History[move.From][move.To] += depth * depth
+	if h.History[move.From][move.To] > maxHistoryScore {
+		h.age()
+	}
+}
+
+// Returns 2 for the most recent killer move at @ply, 1 for the other one and
+// 0 if @move isn't a killer move.
+func (h *HistoryTable) KillerRank(move *Move, ply int) int {
+	if ply >= MaxKillerPly {
+		return 0
+	}
+	for i, killer := range h.Killers[ply] {
+		if killer != nil && *killer == *move {
+			return 2 - i
+		}
+	}
+	return 0
+}
+
+func (h *HistoryTable) GetScore(move *Move) int {
+	return h.History[move.From][move.To]
+}
+
+// Prepares the table for a new search in the same game. Killer moves are
+// relative to the root so they are forgotten, but the history is kept, with
+// less weight.
+func (h *HistoryTable) NewSearch() {
+	h.Killers = [MaxKillerPly][2]*Move{}
+	h.age()
+}
+
+func (h *HistoryTable) Clear() {
+	h.Killers = [MaxKillerPly][2]*Move{}
+	h.History = [64][64]int{}
+}
+
+func (h *HistoryTable) age() {
+	for from := range h.History {
+		for to := range h.History[from] {
+			h.History[from][to] /= 2
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package chess_engine
+
+import (
+	"testing"
+)
+
+func Test_HistoryTable_AddCutoff(t *testing.T) {
+	unit := NewHistoryTable()
+	m1 := NewMove(G1, F3)
+	m2 := NewMove(B1, C3)
+	m3 := NewMove(E2, E4)
+	unit.AddCutoff(m1, 2, 3)
+	unit.AddCutoff(m2, 2, 2)
+	unit.AddCutoff(m2, 2, 2)
+	if unit.KillerRank(m2, 2) != 2 || unit.KillerRank(m1, 2) != 1 {
+		t.Errorf("Expecting g1f3 and b1c3 to be killer moves")
+	}
+	unit.AddCutoff(m3, 2, 1)
+	if unit.KillerRank(m3, 2) != 2 || unit.KillerRank(m2, 2) != 1 || unit.KillerRank(m1, 2) != 0 {
+		t.Errorf("Expecting only the last two moves to be killer moves")
+	}
+	if unit.KillerRank(m3, 3) != 0 {
+		t.Errorf("Expecting killer moves to be per ply")
+	}
+	if unit.GetScore(m1) != 9 || unit.GetScore(m2) != 8 || unit.GetScore(m3) != 1 {
+		t.Errorf("Unexpected history scores %d %d %d", unit.GetScore(m1), unit.GetScore(m2), unit.GetScore(m3))
+	}
+	if unit.GetScore(NewMove(F3, G1)) != 0 {
+		t.Errorf("Expecting history scores to depend on the direction of the move")
+	}
+}
+
+func Test_HistoryTable_NewSearch(t *testing.T) {
+	unit := NewHistoryTable()
+	move := NewMove(G1, F3)
+	unit.AddCutoff(move, 0, 4)
+	unit.NewSearch()
+	if unit.KillerRank(move, 0) != 0 {
+		t.Errorf("Expecting killer moves to be forgotten")
+	}
+	if unit.GetScore(move) != 8 {
+		t.Errorf("Expecting the history score to be halved, got %d", unit.GetScore(move))
+	}
+	unit.AddCutoff(move, 0, 4)
+	unit.Clear()
+	if unit.KillerRank(move, 0) != 0 || unit.GetScore(move) != 0 {
+		t.Errorf("Expecting the table to be cleared")
+	}
+}
@@ -0,0 +1,162 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type EngineOption uint8
+
+const (
+	SELDEPTH EngineOption = iota
+	HASH
+	THREADS
+	MULTIPV
+	EVALUATORS
+)
+
+const (
+	DefaultSelDepth = 4
+	MaxMultiPV      = 256
+)
+
+// The value an EngineOption is set to. Only the field that matches the type
+// of the option is used.
+type OptionValue struct {
+	Int    int
+	Bool   bool
+	String string
+}
+
+func IntValue(i int) OptionValue {
+	return OptionValue{Int: i}
+}
+
+func BoolValue(b bool) OptionValue {
+	return OptionValue{Bool: b}
+}
+
+func StringValue(s string) OptionValue {
+	return OptionValue{String: s}
+}
+
+type OptionType uint8
+
+const (
+	SpinOption OptionType = iota
+	CheckOption
+	StringOption
+)
+
+func (t OptionType) String() string {
+	switch t {
+	case SpinOption:
+		return "spin"
+	case CheckOption:
+		return "check"
+	}
+	return "string"
+}
+
+// An option that we advertise to the GUI in response to the uci command and
+// that can be changed with setoption.
+type UCIOption struct {
+	Name  string
+	Type  OptionType
+	Value OptionValue
+	Min   int
+	Max   int
+
+	// Called after the Value has been changed, e.g. to pass the new value
+	// on to the engine.
+	apply func(OptionValue) error
+}
+
+func NewSpinOption(name string, value, min, max int, apply func(OptionValue) error) *UCIOption {
+	return &UCIOption{
+		Name:  name,
+		Type:  SpinOption,
+		Value: IntValue(value),
+		Min:   min,
+		Max:   max,
+		apply: apply,
+	}
+}
+
+func NewCheckOption(name string, value bool, apply func(OptionValue) error) *UCIOption {
+	return &UCIOption{
+		Name:  name,
+		Type:  CheckOption,
+		Value: BoolValue(value),
+		apply: apply,
+	}
+}
+
+func NewStringOption(name string, value string, apply func(OptionValue) error) *UCIOption {
+	return &UCIOption{
+		Name:  name,
+		Type:  StringOption,
+		Value: StringValue(value),
+		apply: apply,
+	}
+}
+
+// Returns the option as it's advertised to the GUI. We use the current value
+// as the default, so that options set on the command line show up correctly.
+func (o *UCIOption) String() string {
+	switch o.Type {
+	case SpinOption:
+		return fmt.Sprintf("option name %s type spin default %d min %d max %d", o.Name, o.Value.Int, o.Min, o.Max)
+	case CheckOption:
+		return fmt.Sprintf("option name %s type check default %v", o.Name, o.Value.Bool)
+	}
+	value := o.Value.String
+	if value == "" {
+		value = "<empty>"
+	}
+	return fmt.Sprintf("option name %s type string default %s", o.Name, value)
+}
+
+// Parses the value given to setoption according to the type of the option.
+func (o *UCIOption) Parse(value string) (OptionValue, error) {
+	switch o.Type {
+	case SpinOption:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return OptionValue{}, fmt.Errorf("Invalid value for %s: %s", o.Name, value)
+		}
+		if i < o.Min || i > o.Max {
+			return OptionValue{}, fmt.Errorf("Value for %s should be between %d and %d, got %d", o.Name, o.Min, o.Max, i)
+		}
+		return IntValue(i), nil
+	case CheckOption:
+		if strings.EqualFold(value, "true") {
+			return BoolValue(true), nil
+		} else if strings.EqualFold(value, "false") {
+			return BoolValue(false), nil
+		}
+		return OptionValue{}, fmt.Errorf("Invalid value for %s: %s", o.Name, value)
+	}
+	if value == "<empty>" {
+		value = ""
+	}
+	return StringValue(value), nil
+}
+
+// Parses and applies a new value.
+func (o *UCIOption) Set(value string) error {
+	v, err := o.Parse(value)
+	if err != nil {
+		return err
+	}
+	previous := o.Value
+	o.Value = v
+	if o.apply != nil {
+		if err := o.apply(v); err != nil {
+			o.Value = previous
+			return err
+		}
+	}
+	return nil
+}
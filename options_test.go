@@ -0,0 +1,64 @@
+package chess_engine
+
+import (
+	"testing"
+)
+
+func Test_UCIOption_String(t *testing.T) {
+	cases := []struct {
+		option   *UCIOption
+		expected string
+	}{
+		{NewSpinOption("Hash", 16, 1, 1024, nil), "option name Hash type spin default 16 min 1 max 1024"},
+		{NewCheckOption("space", true, nil), "option name space type check default true"},
+		{NewStringOption("Evaluators", "", nil), "option name Evaluators type string default <empty>"},
+		{NewStringOption("Evaluators", "space", nil), "option name Evaluators type string default space"},
+	}
+	for _, testCase := range cases {
+		if testCase.option.String() != testCase.expected {
+			t.Errorf("Expecting '%s', got '%s'", testCase.expected, testCase.option.String())
+		}
+	}
+}
+
+func Test_UCIOption_Set(t *testing.T) {
+	applied := OptionValue{}
+	apply := func(value OptionValue) error {
+		applied = value
+		return nil
+	}
+	spin := NewSpinOption("Depth", 4, 1, 100, apply)
+	if err := spin.Set("10"); err != nil {
+		t.Fatal(err)
+	}
+	if spin.Value.Int != 10 || applied.Int != 10 {
+		t.Errorf("Expecting 10, got %d", spin.Value.Int)
+	}
+	for _, value := range []string{"0", "101", "ten"} {
+		if err := spin.Set(value); err == nil {
+			t.Errorf("Expecting an error for %s", value)
+		}
+	}
+	if spin.Value.Int != 10 {
+		t.Errorf("Expecting the value to be unchanged, got %d", spin.Value.Int)
+	}
+
+	check := NewCheckOption("space", false, apply)
+	if err := check.Set("TRUE"); err != nil {
+		t.Fatal(err)
+	}
+	if !check.Value.Bool || !applied.Bool {
+		t.Errorf("Expecting true")
+	}
+	if err := check.Set("yes"); err == nil {
+		t.Errorf("Expecting an error")
+	}
+
+	str := NewStringOption("Evaluators", "space", apply)
+	if err := str.Set("<empty>"); err != nil {
+		t.Fatal(err)
+	}
+	if str.Value.String != "" {
+		t.Errorf("Expecting an empty string, got %s", str.Value.String)
+	}
+}
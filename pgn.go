@@ -1,9 +1,11 @@
 package chess_engine
 
 import (
-	"bytes"
+	"fmt"
+	"sort"
 	"strconv"
-	"text/template"
+	"strings"
+	"time"
 )
 
 type PGNTags struct {
@@ -18,70 +20,230 @@ type PGNTags struct {
 }
 
 func LineToPGNWithTags(position *Game, line []*Move, tags PGNTags) string {
-	tpl := `[Event "{{.Event}}"]
-[Site "{{.Site}}"]
-[Date "{{.Date}}"]
-[Round "{{.Round}}"]
-[White "{{.White}}"]
-[Black "{{.Black}}"]
-[Result "{{.Result}}"]
-
-`
-	templ, err := template.New("pgn").Parse(tpl)
-	if err != nil {
-		panic(err)
-	}
-	buf := bytes.NewBuffer([]byte{})
-	err = templ.Execute(buf, tags)
-	if err != nil {
-		panic(err)
+	game := NewPGNGame(position, line)
+	game.Tags = tags.ToMap()
+	if tags.Result != "" {
+		game.Result = tags.Result
 	}
-	return buf.String() + LineToPGN(position, line)
+	return game.String()
 }
 
 func LineToPGN(position *Game, line []*Move) string {
+	return NewPGNGame(position, line).Movetext() + "\n"
+}
 
-	result := ""
-	currentLine := ""
-	if position.ToMove == Black {
-		currentLine = "1. ... "
+// Returns the tags as a map, leaving out the ones that are empty.
+func (t PGNTags) ToMap() map[string]string {
+	result := map[string]string{}
+	for name, value := range t.AdditionalTags {
+		result[name] = value
 	}
-	moveNr := 1
+	for name, value := range map[string]string{
+		"Event":  t.Event,
+		"Site":   t.Site,
+		"Date":   t.Date,
+		"Round":  t.Round,
+		"White":  t.White,
+		"Black":  t.Black,
+		"Result": t.Result,
+	} {
+		if value != "" {
+			result[name] = value
+		}
+	}
+	return result
+}
 
-	game := position
+// The Seven Tag Roster, which every PGN game has, in the order they have to
+// be written in.
+var pgnSevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// Creates a game that can be written as PGN from the moves in @line. The
+// result is taken from the final position, so it's "*" if the game isn't
+// over yet.
+func NewPGNGame(position *Game, line []*Move) *PGNGame {
+	game := &PGNGame{
+		Tags:             map[string]string{},
+		StartingPosition: position,
+		Position:         position,
+	}
 	for _, move := range line {
-		if game.ToMove == White {
-			currentLine += strconv.Itoa(moveNr) + ". "
+		san := MoveToAlgebraicMove(game.Position, move)
+		game.Position = game.Position.ApplyMove(move)
+		game.Moves = append(game.Moves, &PGNMove{
+			Move:     move,
+			SAN:      san,
+			Position: game.Position,
+		})
+	}
+	game.Result = "*"
+	if game.Position.IsMate() {
+		if game.Position.ToMove == White {
+			game.Result = "0-1"
+		} else {
+			game.Result = "1-0"
+		}
+	} else if game.Position.IsDraw() {
+		game.Result = "1/2-1/2"
+	}
+	return game
+}
+
+// Returns the game as PGN: the tags, followed by the movetext.
+func (g *PGNGame) String() string {
+	tags := map[string]string{}
+	for name, value := range g.Tags {
+		tags[name] = value
+	}
+	if g.Result != "" {
+		tags["Result"] = g.Result
+	}
+	defaults := map[string]string{"Date": "????.??.??", "Result": "*"}
+	result := ""
+	for _, name := range pgnSevenTagRoster {
+		value, ok := tags[name]
+		if !ok || value == "" {
+			value = defaults[name]
+			if value == "" {
+				value = "?"
+			}
+		}
+		result += pgnTag(name, value)
+		delete(tags, name)
+	}
+	// Games that don't start from the standard position need a FEN tag.
+	delete(tags, "SetUp")
+	delete(tags, "FEN")
+	if g.StartingPosition != nil && g.StartingPosition.FENString() != StartingPositionFEN {
+		result += pgnTag("SetUp", "1")
+		result += pgnTag("FEN", g.StartingPosition.FENString())
+	}
+	names := []string{}
+	for name, value := range tags {
+		if value != "" {
+			names = append(names, name)
 		}
-		algebraic := MoveToAlgebraicMove(game, move)
-		currentLine += algebraic + " "
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result += pgnTag(name, tags[name])
+	}
+	return result + "\n" + g.Movetext() + "\n"
+}
 
-		if game.ToMove == Black {
-			moveNr += 1
+func pgnTag(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return fmt.Sprintf("[%s \"%s\"]\n", name, value)
+}
+
+// Returns the moves, comments and variations of the game, followed by the
+// result, wrapped so that no line is longer than 79 characters.
+func (g *PGNGame) Movetext() string {
+	tokens := []string{}
+	if g.Comment != "" {
+		tokens = append(tokens, "{"+g.Comment+"}")
+	}
+	position := g.StartingPosition
+	if position == nil {
+		position, _ = ParseFEN(StartingPositionFEN)
+	}
+	tokens = appendPGNMoves(tokens, position, g.Moves)
+	if g.Result == "" {
+		tokens = append(tokens, "*")
+	} else {
+		tokens = append(tokens, g.Result)
+	}
+	result := ""
+	line := ""
+	for _, token := range tokens {
+		if line != "" && len(line)+1+len(token) > 79 {
+			result += line + "\n"
+			line = ""
 		}
-		if len(currentLine) > 60 {
-			result += currentLine + "\n"
-			currentLine = ""
+		if line != "" {
+			line += " "
 		}
-		game = game.ApplyMove(move)
+		line += token
 	}
-	if game.IsMate() {
+	return result + line
+}
+
+// Appends the tokens for @moves, which are played from @position. Move
+// numbers come from the position, and black moves get a number as well
+// when they are the first move after a comment or a variation.
+func appendPGNMoves(tokens []string, position *Game, moves []*PGNMove) []string {
+	game := position
+	needsNumber := true
+	for _, move := range moves {
 		if game.ToMove == White {
-			currentLine += " 0-1"
-		} else {
-			currentLine += " 1-0"
+			tokens = append(tokens, strconv.Itoa(game.Fullmove)+".")
+		} else if needsNumber {
+			tokens = append(tokens, strconv.Itoa(game.Fullmove)+"...")
+		}
+		needsNumber = false
+		tokens = append(tokens, MoveToAlgebraicMove(game, move.Move))
+		for _, nag := range move.NAGs {
+			tokens = append(tokens, "$"+strconv.Itoa(nag))
+		}
+		if comment := move.commentString(); comment != "" {
+			tokens = append(tokens, "{"+comment+"}")
+			needsNumber = true
 		}
-	} else if game.IsDraw() {
-		currentLine += "1/2-1/2"
+		for _, variation := range move.Variations {
+			variationTokens := []string{}
+			if variation.Comment != "" {
+				variationTokens = append(variationTokens, "{"+variation.Comment+"}")
+			}
+			variationTokens = appendPGNMoves(variationTokens, game, variation.Moves)
+			if len(variationTokens) == 0 {
+				continue
+			}
+			variationTokens[0] = "(" + variationTokens[0]
+			variationTokens[len(variationTokens)-1] += ")"
+			tokens = append(tokens, variationTokens...)
+			needsNumber = true
+		}
+		game = game.ApplyMove(move.Move)
+	}
+	return tokens
+}
+
+// Returns the comment with the evaluation and the clock in front of it,
+// e.g. "[%eval 0.35] [%clk 0:04:58] Solid".
+func (m *PGNMove) commentString() string {
+	parts := []string{}
+	if m.Eval != nil {
+		parts = append(parts, "[%eval "+formatPGNEval(*m.Eval)+"]")
+	}
+	if m.Clock != nil {
+		parts = append(parts, "[%clk "+formatPGNClock(*m.Clock)+"]")
+	}
+	if m.Comment != "" {
+		parts = append(parts, m.Comment)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Formats a score in pawns, e.g. "0.35", or "#3" for mate in three.
+func formatPGNEval(score Score) string {
+	uci := score.ToUCI()
+	if strings.HasPrefix(uci, "mate ") {
+		return "#" + strings.TrimPrefix(uci, "mate ")
 	}
-	return result + currentLine + "\n"
+	return fmt.Sprintf("%.2f", float64(score)/100)
+}
+
+// Formats a clock as h:mm:ss.
+func formatPGNClock(clock time.Duration) string {
+	seconds := int(clock.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
 }
 
 func MoveToAlgebraicMove(position *Game, move *Move) string {
 	movingPiece := position.Board[move.From]
 	normPiece := movingPiece.ToNormalizedPiece()
 	capture := ""
-	if position.Board[move.To] != NoPiece {
+	if position.IsCapture(move) {
 		capture = "x"
 	}
 	pieceMap := map[NormalizedPiece]string{
@@ -96,7 +258,7 @@ func MoveToAlgebraicMove(position *Game, move *Move) string {
 	if normPiece == Pawn {
 		moveStr := move.To.String()
 		if move.Promote != NoPiece {
-			moveStr += pieceMap[move.Promote.ToNormalizedPiece()]
+			moveStr += "=" + pieceMap[move.Promote.ToNormalizedPiece()]
 		}
 		if capture == "" {
 			result = moveStr
@@ -111,23 +273,26 @@ func MoveToAlgebraicMove(position *Game, move *Move) string {
 			result += "O-O-O"
 		}
 	} else {
+		// Other pieces of the same kind that can move to the same square.
+		// If there are any we add the file, the rank or both to tell them
+		// apart.
 		others := []Position{}
-		fileTheSame := true
-		rankTheSame := true
+		fileUnique := true
+		rankUnique := true
 		for _, other := range position.ValidMoves() {
 			if other.To == move.To && other.From != move.From && position.Board[other.From] == position.Board[move.From] {
 				others = append(others, other.From)
-				fileTheSame = fileTheSame && (other.From.GetFile() == move.From.GetFile())
-				rankTheSame = rankTheSame && (other.From.GetRank() == move.From.GetRank())
+				fileUnique = fileUnique && (other.From.GetFile() != move.From.GetFile())
+				rankUnique = rankUnique && (other.From.GetRank() != move.From.GetRank())
 			}
 
 		}
 		result = pieceMap[normPiece]
 		if len(others) == 0 {
 			result += capture + move.To.String()
-		} else if !fileTheSame {
+		} else if fileUnique {
 			result += string([]byte{byte(move.From.GetFile())}) + capture + move.To.String()
-		} else if !rankTheSame {
+		} else if rankUnique {
 			result += string([]byte{byte(move.From.GetRank())}) + capture + move.To.String()
 		} else {
 			result += move.From.String() + capture + move.To.String()
@@ -141,3 +306,105 @@ func MoveToAlgebraicMove(position *Game, move *Move) string {
 	}
 	return result
 }
+
+var sanPieces = map[byte]NormalizedPiece{
+	'N': Knight,
+	'B': Bishop,
+	'R': Rook,
+	'Q': Queen,
+	'K': King,
+}
+
+// Parses a move in standard algebraic notation (e.g. "Nf3", "exd5", "O-O",
+// "e8=Q+" or "Raxb1!?") and returns the matching valid move in @game.
+// Check and mate suffixes and annotation glyphs are ignored, as are capture
+// markers, so "Nf3" and "Nxf3" are the same move.
+func ParseSAN(game *Game, san string) (*Move, error) {
+	str := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	str = strings.TrimSpace(strings.TrimSuffix(str, "e.p."))
+	switch str {
+	case "O-O", "0-0":
+		return findCastlesMove(game, 'g', san)
+	case "O-O-O", "0-0-0":
+		return findCastlesMove(game, 'c', san)
+	}
+	piece := Pawn
+	if len(str) > 0 {
+		if p, ok := sanPieces[str[0]]; ok {
+			piece = p
+			str = str[1:]
+		}
+	}
+	promote := NoNPiece
+	if i := strings.Index(str, "="); i >= 0 && i == len(str)-2 {
+		p, ok := sanPieces[strings.ToUpper(str[i+1:])[0]]
+		if !ok || p == King {
+			return nil, fmt.Errorf("Invalid promotion in move %s", san)
+		}
+		promote = p
+		str = str[:i]
+	} else if piece == Pawn && len(str) > 0 {
+		if p, ok := sanPieces[str[len(str)-1]]; ok && p != King {
+			promote = p
+			str = str[:len(str)-1]
+		}
+	}
+	str = strings.NewReplacer("x", "", "-", "", ":", "").Replace(str)
+	if len(str) < 2 || len(str) > 4 {
+		return nil, fmt.Errorf("Invalid move %s", san)
+	}
+	to, err := ParsePosition(str[len(str)-2:])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid move %s", san)
+	}
+	fromFile, fromRank := File(0), Rank(0)
+	for _, c := range []byte(str[:len(str)-2]) {
+		if c >= 'a' && c <= 'h' {
+			fromFile = File(c)
+		} else if c >= '1' && c <= '8' {
+			fromRank = Rank(c)
+		} else {
+			return nil, fmt.Errorf("Invalid move %s", san)
+		}
+	}
+
+	var result *Move
+	for _, move := range game.ValidMoves() {
+		if move.To != to || game.Board[move.From].ToNormalizedPiece() != piece {
+			continue
+		}
+		// Castling is only written as O-O or O-O-O, never as a king move.
+		if piece == King && move.GetRookCastlesMove(game.Board[move.From]) != nil {
+			continue
+		}
+		if (fromFile != 0 && move.From.GetFile() != fromFile) || (fromRank != 0 && move.From.GetRank() != fromRank) {
+			continue
+		}
+		if (promote == NoNPiece) != (move.Promote == NoPiece) {
+			continue
+		}
+		if promote != NoNPiece && move.Promote.ToNormalizedPiece() != promote {
+			continue
+		}
+		if result != nil {
+			return nil, fmt.Errorf("Ambiguous move %s in position %s", san, game.FENString())
+		}
+		result = move
+	}
+	if result == nil {
+		return nil, fmt.Errorf("Invalid move %s in position %s", san, game.FENString())
+	}
+	return result, nil
+}
+
+// Returns the valid castling move for the side to move that ends up on
+// @file (g for kingside, c for queenside).
+func findCastlesMove(game *Game, file File, san string) (*Move, error) {
+	for _, move := range game.ValidMoves() {
+		piece := game.Board[move.From]
+		if piece.ToNormalizedPiece() == King && move.To.GetFile() == file && move.GetRookCastlesMove(piece) != nil {
+			return move, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid move %s in position %s", san, game.FENString())
+}
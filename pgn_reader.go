@@ -0,0 +1,548 @@
+package chess_engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A move in the movetext of a PGN game, together with everything that was
+// said about it.
+type PGNMove struct {
+	Move *Move
+	// The move as it was written, e.g. "Nxf3+"
+	SAN string
+	// The position after the move
+	Position *Game
+	// Numeric Annotation Glyphs, e.g. 1 for "!" and 2 for "?"
+	NAGs []int
+	// The comment following the move
+	Comment string
+	// The evaluation after the move from white's point of view, which is
+	// written as [%eval] in the comment.
+	Eval *Score
+	// The time left on the clock of the side that moved, which is written
+	// as [%clk] in the comment.
+	Clock *time.Duration
+	// Alternatives to this move. Every variation starts from the position
+	// before this move.
+	Variations []*PGNVariation
+}
+
+type PGNVariation struct {
+	// The comment before the first move of the variation
+	Comment string
+	Moves   []*PGNMove
+}
+
+type PGNGame struct {
+	Tags map[string]string
+	// The standard starting position, unless the game has a FEN tag
+	StartingPosition *Game
+	// The comment before the first move
+	Comment string
+	// The main line
+	Moves []*PGNMove
+	// The position after the last move of the main line
+	Position *Game
+	Result   string
+}
+
+// Returns the moves of the main line.
+func (g *PGNGame) Line() []*Move {
+	result := make([]*Move, len(g.Moves))
+	for i, move := range g.Moves {
+		result[i] = move.Move
+	}
+	return result
+}
+
+// An error in a PGN file, with the location where it occurred.
+type PGNError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *PGNError) Error() string {
+	return fmt.Sprintf("pgn: line %d, column %d: %s", e.Line, e.Column, e.Err.Error())
+}
+
+type pgnTokenType int
+
+const (
+	pgnEOF pgnTokenType = iota
+	pgnSymbol
+	pgnString
+	pgnComment
+	pgnNAG
+	// [ ] ( ) . and *
+	pgnPunctuation
+)
+
+type pgnToken struct {
+	Type   pgnTokenType
+	Value  string
+	Line   int
+	Column int
+}
+
+var pgnResults = map[string]bool{
+	"1-0":     true,
+	"0-1":     true,
+	"1/2-1/2": true,
+	"*":       true,
+}
+
+// The glyphs that can follow a move instead of a NAG.
+var pgnGlyphs = map[string]int{
+	"!":  1,
+	"?":  2,
+	"!!": 3,
+	"??": 4,
+	"!?": 5,
+	"?!": 6,
+}
+
+// PGNReader reads games from a PGN file one at a time, so that large
+// files don't have to fit in memory.
+type PGNReader struct {
+	reader *bufio.Reader
+	line   int
+	column int
+	peeked *pgnToken
+}
+
+func NewPGNReader(reader io.Reader) *PGNReader {
+	return &PGNReader{
+		reader: bufio.NewReader(reader),
+		line:   1,
+		column: 1,
+	}
+}
+
+// Reads all the games in a PGN file.
+func ReadPGN(reader io.Reader) ([]*PGNGame, error) {
+	r := NewPGNReader(reader)
+	result := []*PGNGame{}
+	for {
+		game, err := r.Next()
+		if err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return result, err
+		}
+		result = append(result, game)
+	}
+}
+
+// Returns the next game, or io.EOF if there are no more games. When a game
+// can't be read the rest of it is skipped, so that Next can be called again
+// to read the games after it.
+func (r *PGNReader) Next() (*PGNGame, error) {
+	token, err := r.next()
+	if err != nil {
+		r.skipGame(false)
+		return nil, err
+	}
+	if token.Type == pgnEOF {
+		return nil, io.EOF
+	}
+	game := &PGNGame{Tags: map[string]string{}}
+	for token.Type == pgnPunctuation && token.Value == "[" {
+		if err := r.readTag(game); err != nil {
+			r.skipGame(false)
+			return nil, err
+		}
+		if token, err = r.next(); err != nil {
+			r.skipGame(false)
+			return nil, err
+		}
+	}
+	r.peeked = token
+
+	game.StartingPosition, err = ParseFEN(StartingPositionFEN)
+	if err != nil {
+		return nil, err
+	}
+	if fen, ok := game.Tags["FEN"]; ok {
+		if game.StartingPosition, err = ParseFEN(fen); err != nil {
+			r.skipGame(false)
+			return nil, r.errorAt(token, fmt.Errorf("Invalid FEN tag: %s", err.Error()))
+		}
+	}
+	moves, comment, result, err := r.readMovetext(game.StartingPosition, false)
+	if err != nil {
+		r.skipGame(true)
+		return nil, err
+	}
+	game.Moves = moves
+	game.Comment = comment
+	game.Position = game.StartingPosition
+	if len(moves) > 0 {
+		game.Position = moves[len(moves)-1].Position
+	}
+	game.Result = result
+	if game.Result == "" {
+		game.Result = game.Tags["Result"]
+	}
+	if game.Result == "" {
+		game.Result = "*"
+	}
+	return game, nil
+}
+
+// Reads a tag pair, e.g. [Event "bs-engine tournament"]. The opening
+// bracket has already been read.
+func (r *PGNReader) readTag(game *PGNGame) error {
+	name, err := r.expect(pgnSymbol, "")
+	if err != nil {
+		return err
+	}
+	value, err := r.expect(pgnString, "")
+	if err != nil {
+		return err
+	}
+	if _, err := r.expect(pgnPunctuation, "]"); err != nil {
+		return err
+	}
+	game.Tags[name.Value] = value.Value
+	return nil
+}
+
+// Reads the moves starting from @position until the end of the game, or
+// the end of the variation if we're in one. Returns the moves, the comment
+// before the first move and the result, if there was one.
+func (r *PGNReader) readMovetext(position *Game, inVariation bool) ([]*PGNMove, string, string, error) {
+	moves := []*PGNMove{}
+	comment := ""
+	game := position
+	// The position before the last move, which is where its variations
+	// start.
+	var previous *Game
+	for {
+		token, err := r.next()
+		if err != nil {
+			return nil, "", "", err
+		}
+		var last *PGNMove
+		if len(moves) > 0 {
+			last = moves[len(moves)-1]
+		}
+		switch token.Type {
+		case pgnEOF:
+			if inVariation {
+				return nil, "", "", r.errorAt(token, fmt.Errorf("Unterminated variation"))
+			}
+			return moves, comment, "", nil
+		case pgnComment:
+			if last == nil {
+				comment = joinComments(comment, token.Value)
+			} else {
+				last.addComment(token.Value)
+			}
+		case pgnNAG:
+			if last == nil {
+				return nil, "", "", r.errorAt(token, fmt.Errorf("Annotation without a move"))
+			}
+			nag, ok := pgnGlyphs[token.Value]
+			if !ok {
+				nag, err = strconv.Atoi(strings.TrimPrefix(token.Value, "$"))
+				if err != nil {
+					return nil, "", "", r.errorAt(token, fmt.Errorf("Invalid annotation %s", token.Value))
+				}
+			}
+			last.NAGs = append(last.NAGs, nag)
+		case pgnString:
+			return nil, "", "", r.errorAt(token, fmt.Errorf("Unexpected string \"%s\"", token.Value))
+		case pgnPunctuation:
+			switch token.Value {
+			case ".":
+			case "*":
+				if inVariation {
+					r.peeked = token
+					return nil, "", "", r.errorAt(token, fmt.Errorf("Unterminated variation"))
+				}
+				return moves, comment, token.Value, nil
+			case "[":
+				if inVariation {
+					r.peeked = token
+					return nil, "", "", r.errorAt(token, fmt.Errorf("Unterminated variation"))
+				}
+				// The result is missing and this is the start of the
+				// next game.
+				r.peeked = token
+				return moves, comment, "", nil
+			case "(":
+				if last == nil {
+					return nil, "", "", r.errorAt(token, fmt.Errorf("Variation without a move"))
+				}
+				variation, variationComment, _, err := r.readMovetext(previous, true)
+				if err != nil {
+					return nil, "", "", err
+				}
+				last.Variations = append(last.Variations, &PGNVariation{variationComment, variation})
+			case ")":
+				if !inVariation {
+					return nil, "", "", r.errorAt(token, fmt.Errorf("Unexpected )"))
+				}
+				return moves, comment, "", nil
+			default:
+				return nil, "", "", r.errorAt(token, fmt.Errorf("Unexpected %s", token.Value))
+			}
+		case pgnSymbol:
+			if pgnResults[token.Value] {
+				if inVariation {
+					r.peeked = token
+					return nil, "", "", r.errorAt(token, fmt.Errorf("Unterminated variation"))
+				}
+				return moves, comment, token.Value, nil
+			}
+			if _, err := strconv.Atoi(token.Value); err == nil {
+				// Move number
+				continue
+			}
+			move, err := ParseSAN(game, token.Value)
+			if err != nil {
+				return nil, "", "", r.errorAt(token, err)
+			}
+			previous = game
+			game = game.ApplyMove(move)
+			moves = append(moves, &PGNMove{Move: move, SAN: token.Value, Position: game})
+		}
+	}
+}
+
+func joinComments(a, b string) string {
+	if a == "" {
+		return b
+	} else if b == "" {
+		return a
+	}
+	return a + " " + b
+}
+
+var pgnCommandRegexp = regexp.MustCompile(`\[%(\w+)\s+([^\]]*)\]`)
+
+// Adds a comment to the move. The [%eval] and [%clk] commands are taken out
+// of the comment and stored on the move. Other commands are left alone.
+func (m *PGNMove) addComment(comment string) {
+	comment = pgnCommandRegexp.ReplaceAllStringFunc(comment, func(command string) string {
+		parts := pgnCommandRegexp.FindStringSubmatch(command)
+		if parts[1] == "eval" {
+			if eval, err := parsePGNEval(parts[2]); err == nil {
+				m.Eval = &eval
+				return ""
+			}
+		} else if parts[1] == "clk" {
+			if clock, err := parsePGNClock(parts[2]); err == nil {
+				m.Clock = &clock
+				return ""
+			}
+		}
+		return command
+	})
+	m.Comment = joinComments(m.Comment, strings.Join(strings.Fields(comment), " "))
+}
+
+// Parses an evaluation in pawns, e.g. "0.35", or "#3" or "#-3" for a mate.
+func parsePGNEval(eval string) (Score, error) {
+	eval = strings.TrimSpace(eval)
+	if strings.HasPrefix(eval, "#") {
+		moves, err := strconv.Atoi(eval[1:])
+		if err != nil || moves == 0 {
+			return 0, fmt.Errorf("Invalid mate score %s", eval)
+		}
+		if moves > 0 {
+			return Mate - Score(2*moves-1), nil
+		}
+		return Score(OpponentMate - 2*moves), nil
+	}
+	pawns, err := strconv.ParseFloat(eval, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid evaluation %s", eval)
+	}
+	return Score(math.Round(pawns * 100)), nil
+}
+
+// Parses a clock, e.g. "1:05:03" or "0:00:59.5".
+func parsePGNClock(clock string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(clock), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("Invalid clock %s", clock)
+	}
+	result := time.Duration(0)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("Invalid clock %s", clock)
+		}
+		unit := time.Second
+		for j := i; j < len(parts)-1; j++ {
+			unit *= 60
+		}
+		result += time.Duration(value * float64(unit))
+	}
+	return result, nil
+}
+
+// Skips the rest of the current game after an error. Once we're in the
+// movetext a tag can only be the start of the next game, so we stop there
+// as well.
+func (r *PGNReader) skipGame(inMovetext bool) {
+	for {
+		token, err := r.next()
+		if err != nil {
+			continue
+		}
+		if token.Type == pgnEOF || pgnResults[token.Value] {
+			return
+		}
+		if inMovetext && token.Type == pgnPunctuation && token.Value == "[" {
+			r.peeked = token
+			return
+		}
+	}
+}
+
+func (r *PGNReader) expect(tokenType pgnTokenType, value string) (*pgnToken, error) {
+	token, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if token.Type != tokenType || (value != "" && token.Value != value) {
+		return nil, r.errorAt(token, fmt.Errorf("Unexpected '%s'", token.Value))
+	}
+	return token, nil
+}
+
+func (r *PGNReader) errorAt(token *pgnToken, err error) error {
+	return &PGNError{Line: token.Line, Column: token.Column, Err: err}
+}
+
+func (r *PGNReader) readRune() (rune, bool) {
+	c, _, err := r.reader.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+	if c == '\n' {
+		r.line++
+		r.column = 1
+	} else {
+		r.column++
+	}
+	return c, true
+}
+
+func (r *PGNReader) peekRune() (rune, bool) {
+	c, _, err := r.reader.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+	r.reader.UnreadRune()
+	return c, true
+}
+
+func isPGNSymbolRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_+#=:-/", c)
+}
+
+// Returns the next token.
+func (r *PGNReader) next() (*pgnToken, error) {
+	if r.peeked != nil {
+		token := r.peeked
+		r.peeked = nil
+		return token, nil
+	}
+	for {
+		line, column := r.line, r.column
+		c, ok := r.readRune()
+		if !ok {
+			return &pgnToken{Type: pgnEOF, Line: line, Column: column}, nil
+		}
+		token := &pgnToken{Line: line, Column: column}
+		switch {
+		case unicode.IsSpace(c) || c == '\ufeff':
+			continue
+		case c == '%' && column == 1:
+			// Escaped line
+			r.readUntil('\n')
+			continue
+		case c == ';':
+			token.Type = pgnComment
+			token.Value = strings.TrimSpace(r.readUntil('\n'))
+		case c == '{':
+			token.Type = pgnComment
+			value := r.readUntil('}')
+			if c, ok := r.readRune(); !ok || c != '}' {
+				return nil, r.errorAt(token, fmt.Errorf("Unterminated comment"))
+			}
+			token.Value = strings.Join(strings.Fields(value), " ")
+		case c == '"':
+			token.Type = pgnString
+			value, err := r.readString()
+			if err != nil {
+				return nil, r.errorAt(token, err)
+			}
+			token.Value = value
+		case c == '$':
+			token.Type = pgnNAG
+			token.Value = "$" + r.readWhile(unicode.IsDigit)
+		case c == '!' || c == '?':
+			token.Type = pgnNAG
+			token.Value = string(c) + r.readWhile(func(c rune) bool { return c == '!' || c == '?' })
+		case strings.ContainsRune("[]().*", c):
+			token.Type = pgnPunctuation
+			token.Value = string(c)
+		case isPGNSymbolRune(c):
+			token.Type = pgnSymbol
+			token.Value = string(c) + r.readWhile(isPGNSymbolRune)
+		default:
+			return nil, r.errorAt(token, fmt.Errorf("Unexpected character '%c'", c))
+		}
+		return token, nil
+	}
+}
+
+// Reads up to, but not including, @end.
+func (r *PGNReader) readUntil(end rune) string {
+	return r.readWhile(func(c rune) bool { return c != end })
+}
+
+func (r *PGNReader) readWhile(f func(rune) bool) string {
+	result := []rune{}
+	for {
+		c, ok := r.peekRune()
+		if !ok || !f(c) {
+			return string(result)
+		}
+		r.readRune()
+		result = append(result, c)
+	}
+}
+
+// Reads a string, after the opening quote, handling \" and \\ escapes.
+func (r *PGNReader) readString() (string, error) {
+	result := []rune{}
+	for {
+		c, ok := r.readRune()
+		if !ok || c == '\n' {
+			return "", fmt.Errorf("Unterminated string")
+		}
+		if c == '"' {
+			return string(result), nil
+		}
+		if c == '\\' {
+			if c, ok = r.readRune(); !ok {
+				return "", fmt.Errorf("Unterminated string")
+			}
+		}
+		result = append(result, c)
+	}
+}
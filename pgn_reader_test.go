@@ -0,0 +1,150 @@
+package chess_engine
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const testPGN = `[Event "Test"]
+[White "bs-engine"]
+[Black "Someone \"quoted\""]
+[Result "1-0"]
+
+{Opening comment} 1. e4 e5 2. Nf3 $1 Nc6 (2... d6 {Philidor} 3. d4 (3. Bc4
+Be7) exd4) (2... Nf6?!) 3. Bb5!! a6 ; Morphy
+4. Ba4 1-0
+
+% This line is ignored
+[Event "From a position"]
+[SetUp "1"]
+[FEN "7k/8/6K1/8/8/8/8/5Q2 b - - 0 1"]
+
+1... Kg8 2. Qf7+ Kh8 3. Qg7# 1-0
+`
+
+func Test_ReadPGN(t *testing.T) {
+	games, err := ReadPGN(strings.NewReader(testPGN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("Expecting 2 games, got %d", len(games))
+	}
+	game := games[0]
+	if game.Tags["Event"] != "Test" || game.Tags["Black"] != `Someone "quoted"` {
+		t.Errorf("Unexpected tags %v", game.Tags)
+	}
+	if Line(game.Line()).String() != "e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 b5a4" {
+		t.Errorf("Unexpected main line %s", Line(game.Line()))
+	}
+	if game.Comment != "Opening comment" || game.Result != "1-0" {
+		t.Errorf("Unexpected comment '%s' or result '%s'", game.Comment, game.Result)
+	}
+	if len(game.Moves[2].NAGs) != 1 || game.Moves[2].NAGs[0] != 1 {
+		t.Errorf("Expecting $1 for Nf3, got %v", game.Moves[2].NAGs)
+	}
+	if len(game.Moves[4].NAGs) != 1 || game.Moves[4].NAGs[0] != 3 {
+		t.Errorf("Expecting $3 for Bb5, got %v", game.Moves[4].NAGs)
+	}
+	if game.Moves[5].Comment != "Morphy" {
+		t.Errorf("Expecting a comment for a6, got '%s'", game.Moves[5].Comment)
+	}
+	variations := game.Moves[3].Variations
+	if len(variations) != 2 {
+		t.Fatalf("Expecting 2 variations for Nc6, got %d", len(variations))
+	}
+	philidor := variations[0].Moves
+	if len(philidor) != 3 || philidor[0].Move.String() != "d7d6" || philidor[0].Comment != "Philidor" || philidor[2].Move.String() != "e5d4" {
+		t.Errorf("Unexpected variation %v", philidor)
+	}
+	if len(philidor[1].Variations) != 1 || Line([]*Move{philidor[1].Variations[0].Moves[0].Move, philidor[1].Variations[0].Moves[1].Move}).String() != "f1c4 f8e7" {
+		t.Errorf("Unexpected nested variation %v", philidor[1].Variations)
+	}
+	if variations[1].Moves[0].Move.String() != "g8f6" || variations[1].Moves[0].NAGs[0] != 6 {
+		t.Errorf("Unexpected variation %v", variations[1].Moves)
+	}
+	if game.Position.FENString() != "r1bqkbnr/1ppp1ppp/p1n5/4p3/B3P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 1 4" {
+		t.Errorf("Unexpected position %s", game.Position.FENString())
+	}
+	if game.Moves[1].Position.FENString() != "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2" {
+		t.Errorf("Unexpected position after e5 %s", game.Moves[1].Position.FENString())
+	}
+	if philidor[0].Position.FENString() != "rnbqkbnr/ppp2ppp/3p4/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 3" {
+		t.Errorf("Unexpected position after d6 %s", philidor[0].Position.FENString())
+	}
+
+	game = games[1]
+	if game.StartingPosition.FENString() != "7k/8/6K1/8/8/8/8/5Q2 b - - 0 1" {
+		t.Errorf("Expecting the position from the FEN tag, got %s", game.StartingPosition.FENString())
+	}
+	if !game.Position.IsMate() || game.Result != "1-0" {
+		t.Errorf("Expecting white to have mated, got %s", game.Position.FENString())
+	}
+}
+
+func Test_ReadPGN_reads_LineToPGNWithTags(t *testing.T) {
+	start, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []*Move{MustParseMove("g2g4"), MustParseMove("e7e5"), MustParseMove("f2f3"), MustParseMove("d8h4")}
+	pgn := LineToPGNWithTags(start, line, PGNTags{Event: "bs-engine tournament", Result: "0-1"})
+	// The tournament appends games to the same file
+	games, err := ReadPGN(strings.NewReader(pgn + "\n" + pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("Expecting 2 games, got %d", len(games))
+	}
+	for _, game := range games {
+		if Line(game.Line()).String() != Line(line).String() || game.Result != "0-1" || game.Tags["Event"] != "bs-engine tournament" {
+			t.Errorf("Unexpected game %v", game)
+		}
+	}
+}
+
+func Test_PGNReader_errors(t *testing.T) {
+	pgn := `[Event "Illegal"]
+
+1. e4 e5 2. Ke3 Nc6 1-0
+
+[Event "Fine"]
+
+1. d4 d5 *
+
+[Event "Unterminated"]
+
+1. d4 (1. e4 *
+
+[Event "Bad tag]
+
+1. c4 *
+`
+	r := NewPGNReader(strings.NewReader(pgn))
+	_, err := r.Next()
+	pgnErr, ok := err.(*PGNError)
+	if !ok {
+		t.Fatalf("Expecting a PGNError, got %v", err)
+	}
+	if pgnErr.Line != 3 || pgnErr.Column != 13 || !strings.Contains(pgnErr.Error(), "Ke3") {
+		t.Errorf("Expecting an error about Ke3 at 3:13, got %s", pgnErr)
+	}
+	game, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Tags["Event"] != "Fine" || len(game.Moves) != 2 {
+		t.Errorf("Expecting the second game, got %v", game.Tags)
+	}
+	if _, err = r.Next(); err == nil || !strings.Contains(err.Error(), "line 11, column 14: Unterminated variation") {
+		t.Errorf("Expecting an unterminated variation, got %v", err)
+	}
+	if _, err = r.Next(); err == nil || !strings.Contains(err.Error(), "line 13") {
+		t.Errorf("Expecting an unterminated string, got %v", err)
+	}
+	if _, err = r.Next(); err != io.EOF {
+		t.Errorf("Expecting EOF, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package chess_engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LineToPGN_insufficient_material(t *testing.T) {
+	unit, err := ParseFEN("k7/8/8/8/8/8/1n6/KB6 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgn := LineToPGN(unit, []*Move{MustParseMove("a1b2")})
+	expected := "1. Kxb2 1/2-1/2\n"
+	if pgn != expected {
+		t.Errorf("Expecting %q, got %q", expected, pgn)
+	}
+}
+
+func Test_ParseSAN(t *testing.T) {
+	cases := [][]string{
+		[]string{StartingPositionFEN, "Nf3", "g1f3"},
+		[]string{StartingPositionFEN, "e4", "e2e4"},
+		[]string{StartingPositionFEN, "Ng1-f3", "g1f3"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0+", "e1c1"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O", "e8c8"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Kf1", "e1f1"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Rab1", "a1b1"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Rxa8+", "a1a8"},
+		[]string{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Rhf1!?", "h1f1"},
+		[]string{"4k3/8/8/8/8/R7/8/R3K3 w - - 0 1", "R1a2", "a1a2"},
+		[]string{"4k3/8/8/8/8/R7/8/R3K3 w - - 0 1", "R3a2", "a3a2"},
+		[]string{"7k/8/8/8/2Q1Q3/8/2Q5/4K3 w - - 0 1", "Qc4d3", "c4d3"},
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8=Q", "b7b8Q"},
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8N", "b7b8N"},
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8=r+", "b7b8R"},
+		[]string{"2r1k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "bxc8=Q+", "b7c8Q"},
+		[]string{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6 e.p.", "e5d6"},
+		[]string{"7k/8/6K1/8/8/8/8/5Q2 w - - 0 1", "Qf8#", "f1f8"},
+	}
+	for _, testCase := range cases {
+		game, err := ParseFEN(testCase[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, err := ParseSAN(game, testCase[1])
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", testCase[1], err)
+			continue
+		}
+		if move.String() != testCase[2] {
+			t.Errorf("Expecting %s for %s, got %s", testCase[2], testCase[1], move)
+		}
+	}
+}
+
+func Test_ParseSAN_errors(t *testing.T) {
+	cases := [][]string{
+		[]string{StartingPositionFEN, ""},
+		[]string{StartingPositionFEN, "e5"},
+		[]string{StartingPositionFEN, "Nf4"},
+		[]string{StartingPositionFEN, "O-O"},
+		[]string{StartingPositionFEN, "Zf3"},
+		[]string{StartingPositionFEN, "e2e3e4"},
+		[]string{"4k3/8/8/8/8/R7/8/R3K3 w - - 0 1", "Ra2"},
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8"},
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b8=K"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Kg1"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "Ke1-c1"},
+		[]string{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "Kc8"},
+	}
+	for _, testCase := range cases {
+		game, err := ParseFEN(testCase[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if move, err := ParseSAN(game, testCase[1]); err == nil {
+			t.Errorf("Expecting an error for '%s', got %s", testCase[1], move)
+		}
+	}
+}
+
+func Test_ParseSAN_round_trips_MoveToAlgebraicMove(t *testing.T) {
+	fens := []string{
+		StartingPositionFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1",
+		"7k/8/8/8/2Q1Q3/8/2Q5/4K3 w - - 0 1",
+	}
+	for _, fen := range fens {
+		game, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, move := range game.ValidMoves() {
+			san := MoveToAlgebraicMove(game, move)
+			parsed, err := ParseSAN(game, san)
+			if err != nil {
+				t.Errorf("Failed to parse %s in %s: %s", san, fen, err)
+			} else if *parsed != *move {
+				t.Errorf("Expecting %s for %s in %s, got %s", move, san, fen, parsed)
+			}
+		}
+	}
+}
+
+func Test_MoveToAlgebraicMove_promotions(t *testing.T) {
+	cases := [][]string{
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8Q", "b8=Q+"},
+		[]string{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8N", "b8=N"},
+		[]string{"2r1k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7c8R", "bxc8=R+"},
+	}
+	for _, testCase := range cases {
+		game, err := ParseFEN(testCase[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if san := MoveToAlgebraicMove(game, MustParseMove(testCase[1])); san != testCase[2] {
+			t.Errorf("Expecting %s for %s in %s, got %s", testCase[2], testCase[1], testCase[0], san)
+		}
+	}
+}
+
+func Test_LineToPGNWithTags(t *testing.T) {
+	unit, err := ParseFEN("7k/8/6K1/8/8/8/8/5Q2 b - - 3 12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []*Move{MustParseMove("h8g8"), MustParseMove("f1f7"), MustParseMove("g8h8"), MustParseMove("f7g7")}
+	tags := PGNTags{
+		Event:  "Test",
+		White:  "bs-engine",
+		Black:  "Someone \"quoted\"",
+		Result: "1-0",
+		AdditionalTags: map[string]string{
+			"Termination": "normal",
+			"TimeControl": "40/300",
+		},
+	}
+	expected := `[Event "Test"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "bs-engine"]
+[Black "Someone \"quoted\""]
+[Result "1-0"]
+[SetUp "1"]
+[FEN "7k/8/6K1/8/8/8/8/5Q2 b - - 3 12"]
+[Termination "normal"]
+[TimeControl "40/300"]
+
+12... Kg8 13. Qf7+ Kh8 14. Qg7# 1-0
+`
+	if pgn := LineToPGNWithTags(unit, line, tags); pgn != expected {
+		t.Errorf("Expecting\n%s\ngot\n%s", expected, pgn)
+	}
+}
+
+func Test_PGNGame_String_writes_annotations(t *testing.T) {
+	start, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewPGNGame(start, []*Move{MustParseMove("e2e4"), MustParseMove("e7e5"), MustParseMove("g1f3")})
+	game.Comment = "A classic"
+	eval, mate, clock := Score(35), Mate-Score(3), 4*time.Minute+58*time.Second
+	game.Moves[0].Eval = &eval
+	game.Moves[0].Clock = &clock
+	game.Moves[1].NAGs = []int{1}
+	game.Moves[1].Variations = []*PGNVariation{
+		&PGNVariation{Comment: "Or", Moves: []*PGNMove{
+			&PGNMove{Move: MustParseMove("c7c5"), Comment: "Sicilian", Variations: []*PGNVariation{
+				&PGNVariation{Moves: []*PGNMove{&PGNMove{Move: MustParseMove("e7e6")}}},
+			}},
+			&PGNMove{Move: MustParseMove("g1f3")},
+		}},
+	}
+	game.Moves[2].Eval = &mate
+	expected := "{A classic} 1. e4 {[%eval 0.35] [%clk 0:04:58]} 1... e5 $1 ({Or} 1... c5\n" +
+		"{Sicilian} (1... e6) 2. Nf3) 2. Nf3 {[%eval #2]} *"
+	if movetext := game.Movetext(); movetext != expected {
+		t.Errorf("Expecting\n%s\ngot\n%s", expected, movetext)
+	}
+
+	// And we should be able to read it back
+	games, err := ReadPGN(strings.NewReader(game.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := games[0]
+	if *read.Moves[0].Eval != eval || *read.Moves[0].Clock != clock || *read.Moves[2].Eval != mate {
+		t.Errorf("Expecting the evaluations and clock to be read back, got %v", read.Moves)
+	}
+	if read.String() != game.String() {
+		t.Errorf("Expecting\n%s\ngot\n%s", game.String(), read.String())
+	}
+}
+
+func Test_PGNGame_String_round_trips_ReadPGN(t *testing.T) {
+	games, err := ReadPGN(strings.NewReader(testPGN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, game := range games {
+		written := game.String()
+		again, err := ReadPGN(strings.NewReader(written))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(again) != 1 || again[0].String() != written {
+			t.Errorf("Expecting the game to survive a round trip:\n%s", written)
+		}
+		for _, line := range strings.Split(written, "\n") {
+			if len(line) > 79 {
+				t.Errorf("Line is too long: %s", line)
+			}
+		}
+	}
+}
+
+func Test_parsePGNClock(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1:05:03":   time.Hour + 5*time.Minute + 3*time.Second,
+		"0:00:59.5": 59500 * time.Millisecond,
+		"2:30":      150 * time.Second,
+	}
+	for clock, expected := range cases {
+		if parsed, err := parsePGNClock(clock); err != nil || parsed != expected {
+			t.Errorf("Expecting %s for %s, got %s (%v)", expected, clock, parsed, err)
+		}
+	}
+}
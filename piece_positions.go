@@ -65,6 +65,23 @@ func (p PiecePositions) Phase() int {
 	return phase // Max is 256 (16*2=32, 6*4=24, 12*4=48, 16*4=64, 44*2=88, 32+24+48+64+88=256)
 }
 
+// Returns whether neither side has enough material left to checkmate: K v K,
+// K+N v K and positions where all the remaining bishops (if any) are on
+// squares of the same colour.
+func (p PiecePositions) IsInsufficientMaterial() bool {
+	for _, color := range Colors {
+		if !p[color][Pawn].IsEmpty() || !p[color][Rook].IsEmpty() || !p[color][Queen].IsEmpty() {
+			return false
+		}
+	}
+	knights := p[White][Knight].Count() + p[Black][Knight].Count()
+	bishops := p[White][Bishop] | p[Black][Bishop]
+	if knights > 0 {
+		return knights == 1 && bishops.IsEmpty()
+	}
+	return bishops&LightSquares == 0 || bishops&DarkSquares == 0
+}
+
 func (p PiecePositions) Count() int {
 	return p.CountPositionsForColor(White) + p.CountPositionsForColor(Black)
 }
@@ -9,6 +9,12 @@ import (
 // track all the positions on the board.
 type PositionBitmap uint64
 
+// Masks for the light and dark squares on the board (a1 is a dark square).
+const (
+	LightSquares PositionBitmap = 0x55AA55AA55AA55AA
+	DarkSquares  PositionBitmap = 0xAA55AA55AA55AA55
+)
+
 func (p PositionBitmap) Add(pos Position) PositionBitmap {
 	return p | (1 << pos)
 }
@@ -53,6 +53,20 @@ func (q *Queue) QueueNextLine(pos *Game, seen SeenMap, depth int, evaluators Eva
 	return false
 }
 
+// Queues every move in the position that hasn't been looked at yet.
+func (q *Queue) QueueAllMoves(pos *Game, seen SeenMap) bool {
+	q.List.PushFront(pos)
+	queued := false
+	for _, nextGame := range pos.NextGames() {
+		if !seen.Seen(nextGame) {
+			seen.Set(nextGame)
+			q.List.PushFront(nextGame)
+			queued = true
+		}
+	}
+	return queued
+}
+
 func (q *Queue) QueueToQuietPosition(pos *Game, seen SeenMap, depth int, evaluators Evaluators) bool {
 
 	newLine, _ := evaluators.GetLineToQuietPosition(pos, depth)
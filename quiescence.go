@@ -0,0 +1,149 @@
+package chess_engine
+
+import (
+	"sort"
+)
+
+// Captures that can't bring the score within this margin of alpha are not
+// worth looking at (delta pruning).
+const DeltaPruningMargin = 200
+
+// The maximum number of plies the quiescence search extends a position by.
+const MaxQuiescenceDepth = 16
+
+// Quiescence extends a leaf position over captures, promotions and check
+// evasions until the position is quiet, so that we don't evaluate positions
+// in the middle of an exchange. The side to move can always choose not to
+// capture anything (standing pat) unless it's in check. The score is from
+// the perspective of the side to move and @ply is the distance from the root
+// of the search, which is used for mate scores. Also returns the number of
+// nodes that were evaluated.
+func (e Evaluators) Quiescence(position *Game, alpha, beta Score, ply int) (Score, int) {
+	// Captures reset the repetition count, so we only have to look for
+	// repetitions here and not in every position of the quiescence search.
+	if position.IsDraw() {
+		return Draw, 1
+	}
+	return e.quiescence(position, alpha, beta, ply, MaxQuiescenceDepth, map[uint64]quiescenceEntry{})
+}
+
+// The result of searching a position in the quiescence search. Capture
+// sequences often transpose, so these are kept for the duration of a
+// Quiescence call.
+type quiescenceEntry struct {
+	Score Score
+	Bound BoundType
+	Depth int
+}
+
+func (e Evaluators) quiescence(position *Game, alpha, beta Score, ply, depth int, seen map[uint64]quiescenceEntry) (Score, int) {
+	nodes := 1
+	if entry, ok := seen[position.Hash]; ok && entry.Depth >= depth {
+		if entry.Bound == ExactBound ||
+			(entry.Bound == LowerBound && entry.Score >= beta) ||
+			(entry.Bound == UpperBound && entry.Score <= alpha) {
+			return entry.Score, nodes
+		}
+	}
+	originalAlpha := alpha
+	if depth < MaxQuiescenceDepth && position.IsInsufficientMaterial() {
+		return Draw, nodes
+	}
+	inCheck := position.InCheck()
+	if inCheck && position.IsMate() {
+		return Score(OpponentMate + ply), nodes
+	}
+	score, _ := e.Eval(position)
+	// Eval scores from the perspective of the side that just moved.
+	standPat := score * -1
+	if depth == 0 {
+		return standPat, nodes
+	}
+
+	bestScore := LowestScore
+	if !inCheck {
+		if standPat >= beta {
+			return standPat, nodes
+		}
+		if standPat > alpha {
+			alpha = standPat
+		}
+		bestScore = standPat
+	}
+
+	for _, move := range e.quiescenceMoves(position, inCheck) {
+		if !inCheck && move.Promote == NoPiece {
+			if standPat+Score(MaterialScores[capturedPiece(position, move)]+DeltaPruningMargin) < alpha {
+				continue
+			}
+		}
+		next := position.ApplyMove(move)
+		score, n := e.quiescence(next, -beta, -alpha, ply+1, depth-1, seen)
+		nodes += n
+		score = score * -1
+		if score > bestScore {
+			bestScore = score
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	bound := ExactBound
+	if bestScore >= beta {
+		bound = LowerBound
+	} else if bestScore <= originalAlpha {
+		bound = UpperBound
+	}
+	seen[position.Hash] = quiescenceEntry{Score: bestScore, Bound: bound, Depth: depth}
+	return bestScore, nodes
+}
+
+// Returns all the moves when we're in check, or the captures and promotions
+// otherwise. Captures that give up a more valuable piece for a defended one
+// are left out, and the rest are ordered by most valuable victim, then least
+// valuable attacker.
+func (e Evaluators) quiescenceMoves(position *Game, inCheck bool) []*Move {
+	moves := position.ValidMoves()
+	if inCheck {
+		return moves
+	}
+	opponent := position.ToMove.Opposite()
+	result := []*Move{}
+	for _, move := range moves {
+		if move.Promote != NoPiece {
+			result = append(result, move)
+		} else if position.IsCapture(move) {
+			attacker := MaterialScores[position.Board[move.From].ToNormalizedPiece()]
+			if attacker > MaterialScores[capturedPiece(position, move)] && position.SquareControl.AttacksSquare(opponent, move.To) {
+				continue
+			}
+			result = append(result, move)
+		}
+	}
+	value := func(move *Move) int {
+		v := MaterialScores[capturedPiece(position, move)] * 10
+		if move.Promote != NoPiece {
+			v += MaterialScores[move.Promote.ToNormalizedPiece()] * 10
+		}
+		return v - MaterialScores[position.Board[move.From].ToNormalizedPiece()]
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return value(result[i]) > value(result[j])
+	})
+	return result
+}
+
+// Returns the piece that @move captures, which for en passant isn't on the
+// square the pawn moves to, or NoNPiece.
+func capturedPiece(position *Game, move *Move) NormalizedPiece {
+	if position.Board[move.To] != NoPiece {
+		return position.Board[move.To].ToNormalizedPiece()
+	}
+	if position.IsCapture(move) {
+		return Pawn
+	}
+	return NoNPiece
+}
@@ -0,0 +1,78 @@
+package chess_engine
+
+import (
+	"testing"
+)
+
+func Test_Quiescence_quiet_position(t *testing.T) {
+	unit := Evaluators([]Evaluator{NaiveMaterialEvaluator})
+	position, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	score, nodes := unit.Quiescence(position, LowestScore+1, -(LowestScore + 1), 0)
+	if score != 0 {
+		t.Errorf("Expecting score 0 in the opening position, got %d", score)
+	}
+	if nodes != 1 {
+		t.Errorf("Expecting only the position itself to be evaluated, got %d nodes", nodes)
+	}
+}
+
+func Test_Quiescence_recapture(t *testing.T) {
+	unit := Evaluators([]Evaluator{NaiveMaterialEvaluator})
+	// White just took the pawn on d7 with the queen, but the king can take
+	// back.
+	position, err := ParseFEN("4k3/3Q4/8/8/8/8/8/4K3 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	static, _ := unit.Eval(position)
+	if static <= 0 {
+		t.Fatalf("Expecting a good static evaluation for white, got %d", static)
+	}
+	score, _ := unit.Quiescence(position, LowestScore+1, -(LowestScore + 1), 0)
+	if score != 0 {
+		t.Errorf("Expecting an equal position for black after recapturing, got %d", score)
+	}
+}
+
+func Test_Quiescence_mate(t *testing.T) {
+	unit := Evaluators([]Evaluator{NaiveMaterialEvaluator})
+	position, err := ParseFEN("r4b2/p3pB2/3N4/6Q1/6kp/P1N1B3/1PP2PPP/R3K2R b KQ - 45 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	score, _ := unit.Quiescence(position, LowestScore+1, -(LowestScore + 1), 3)
+	if score != OpponentMate+3 {
+		t.Errorf("Expecting to be mated, got %d", score)
+	}
+}
+
+func Test_Quiescence_en_passant_is_not_pruned(t *testing.T) {
+	unit := Evaluators([]Evaluator{NaiveMaterialEvaluator})
+	position, err := ParseFEN("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Winning a pawn brings the score within the delta pruning margin of
+	// alpha, so exd6 has to be looked at.
+	_, nodes := unit.Quiescence(position, 250, 1000, 0)
+	if nodes < 2 {
+		t.Errorf("Expecting the en passant capture to be searched, got %d nodes", nodes)
+	}
+}
+
+func Test_Quiescence_skips_losing_captures(t *testing.T) {
+	unit := Evaluators([]Evaluator{NaiveMaterialEvaluator})
+	// The pawn on d5 is defended, so taking it with the queen loses
+	// material, but taking it with the pawn doesn't.
+	position, err := ParseFEN("4k3/8/4p3/3p4/4P3/8/8/3QK3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := unit.quiescenceMoves(position, false)
+	if len(moves) != 1 || moves[0].String() != "e4d5" {
+		t.Errorf("Expecting only e4d5, got %v", moves)
+	}
+}
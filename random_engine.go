@@ -21,11 +21,27 @@ func (b *RandomEngine) SetPosition(fen *Game) {
 func (b *RandomEngine) AddEvaluator(eval Evaluator) {
 	fmt.Println("This is a random engine...ignoring the evaluator")
 }
-func (b *RandomEngine) Start(output chan string, maxNodes, maxDepth int) {
-	nextGames := b.StartingPosition.NextGames()
+func (b *RandomEngine) Start(output chan string, limits *SearchLimits) {
+	nextGames := []*Game{}
+	for _, next := range b.StartingPosition.NextGames() {
+		if limits.IsSearchMove(next.Line[len(next.Line)-1]) {
+			nextGames = append(nextGames, next)
+		}
+	}
+	if len(nextGames) == 0 {
+		if err := limits.CheckSearchMoves(b.StartingPosition); err != nil {
+			output <- "info string error: " + err.Error()
+		}
+		output <- "bestmove (none)"
+		return
+	}
 	board := nextGames[rand.Intn(len(nextGames))]
 	output <- fmt.Sprintf("bestmove %s", board.Line[0])
 }
 
-func (b *RandomEngine) Stop()                               {}
-func (b *RandomEngine) SetOption(opt EngineOption, val int) {}
+func (b *RandomEngine) Stop()      {}
+func (b *RandomEngine) PonderHit() {}
+func (b *RandomEngine) NewGame()   {}
+func (b *RandomEngine) SetOption(opt EngineOption, val OptionValue) error {
+	return nil
+}
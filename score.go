@@ -19,6 +19,19 @@ func (s Score) ToCentipawn() int {
 	return int(s)
 }
 
+// Formats the score for UCI info output, e.g. "cp 35" or "mate -2". Mate
+// scores are given in moves rather than plies.
+func (s Score) ToUCI() string {
+	if s >= Mate-maxMatePlies && s <= Mate {
+		plies := int(Mate - s)
+		return fmt.Sprintf("mate %d", (plies+1)/2)
+	} else if s <= OpponentMate+maxMatePlies && s >= OpponentMate {
+		plies := int(s - OpponentMate)
+		return fmt.Sprintf("mate -%d", (plies+1)/2)
+	}
+	return fmt.Sprintf("cp %d", s.ToCentipawn())
+}
+
 func (s Score) IsMateIn(n int) bool {
 	return Mate-Score(n) == s
 }
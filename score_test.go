@@ -12,3 +12,19 @@ func Test_Score_IsMateIn(t *testing.T) {
 		t.Errorf("Expecting mate in 5")
 	}
 }
+
+func Test_Score_ToUCI(t *testing.T) {
+	cases := map[Score]string{
+		Score(35):               "cp 35",
+		Score(-120):             "cp -120",
+		Mate - 1:                "mate 1",
+		Mate - 3:                "mate 2",
+		Score(OpponentMate + 2): "mate -1",
+		Score(OpponentMate + 4): "mate -2",
+	}
+	for score, expected := range cases {
+		if score.ToUCI() != expected {
+			t.Errorf("Expecting %s for %d, got %s", expected, score, score.ToUCI())
+		}
+	}
+}
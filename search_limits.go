@@ -0,0 +1,199 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchLimits holds the arguments to the UCI go command, which tell the
+// engine how long it's allowed to search for.
+type SearchLimits struct {
+	WhiteTime      time.Duration
+	BlackTime      time.Duration
+	WhiteIncrement time.Duration
+	BlackIncrement time.Duration
+	MovesToGo      int
+	MoveTime       time.Duration
+	Depth          int
+	Nodes          int
+	Mate           int
+	Infinite       bool
+	// Search on the opponent's time, assuming they play the move we expect.
+	Ponder bool
+	// Only consider these moves at the root. All moves are considered when
+	// this is empty.
+	SearchMoves []*Move
+}
+
+func NewSearchLimits() *SearchLimits {
+	return &SearchLimits{}
+}
+
+// Parses the arguments to the UCI go command, e.g.
+// "wtime 60000 btime 60000 winc 1000 binc 1000". Unknown tokens are ignored,
+// as required by the protocol.
+func ParseSearchLimits(args []string) (*SearchLimits, error) {
+	limits := NewSearchLimits()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "infinite":
+			limits.Infinite = true
+			continue
+		case "ponder":
+			limits.Ponder = true
+			continue
+		case "searchmoves":
+			for i+1 < len(args) {
+				move, err := ParseMove(args[i+1])
+				if err != nil {
+					break
+				}
+				limits.SearchMoves = append(limits.SearchMoves, move)
+				i++
+			}
+			if len(limits.SearchMoves) == 0 {
+				return nil, fmt.Errorf("Missing moves for searchmoves")
+			}
+			continue
+		case "wtime", "btime", "winc", "binc", "movetime", "movestogo", "depth", "nodes", "mate":
+		default:
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("Missing value for %s", arg)
+		}
+		i++
+		value, err := strconv.Atoi(args[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for %s: %s", arg, args[i])
+		}
+		ms := time.Duration(value) * time.Millisecond
+		switch arg {
+		case "wtime":
+			limits.WhiteTime = ms
+		case "btime":
+			limits.BlackTime = ms
+		case "winc":
+			limits.WhiteIncrement = ms
+		case "binc":
+			limits.BlackIncrement = ms
+		case "movetime":
+			limits.MoveTime = ms
+		case "movestogo":
+			limits.MovesToGo = value
+		case "depth":
+			limits.Depth = value
+		case "nodes":
+			limits.Nodes = value
+		case "mate":
+			limits.Mate = value
+		}
+	}
+	return limits, nil
+}
+
+// Returns the limits as arguments to the go command.
+func (l *SearchLimits) String() string {
+	args := []string{}
+	if l.Ponder {
+		args = append(args, "ponder")
+	}
+	if l.Infinite {
+		args = append(args, "infinite")
+	}
+	for _, arg := range []struct {
+		name  string
+		value int64
+	}{
+		{"wtime", l.WhiteTime.Milliseconds()},
+		{"btime", l.BlackTime.Milliseconds()},
+		{"winc", l.WhiteIncrement.Milliseconds()},
+		{"binc", l.BlackIncrement.Milliseconds()},
+		{"movestogo", int64(l.MovesToGo)},
+		{"movetime", l.MoveTime.Milliseconds()},
+		{"depth", int64(l.Depth)},
+		{"nodes", int64(l.Nodes)},
+		{"mate", int64(l.Mate)},
+	} {
+		if arg.value != 0 {
+			args = append(args, fmt.Sprintf("%s %d", arg.name, arg.value))
+		}
+	}
+	if len(l.SearchMoves) > 0 {
+		args = append(args, "searchmoves "+Line(l.SearchMoves).String())
+	}
+	if len(args) == 0 {
+		return "none"
+	}
+	return strings.Join(args, " ")
+}
+
+// Returns the remaining time and increment for @color.
+func (l *SearchLimits) Clock(color Color) (time.Duration, time.Duration) {
+	if color == Black {
+		return l.BlackTime, l.BlackIncrement
+	}
+	return l.WhiteTime, l.WhiteIncrement
+}
+
+// Returns whether there is a time limit on the search.
+func (l *SearchLimits) IsTimed(color Color) bool {
+	remaining, _ := l.Clock(color)
+	return !l.Infinite && (l.MoveTime > 0 || remaining > 0)
+}
+
+// Returns how many plies deep the engine should search: the depth limit if
+// there is one, otherwise deep enough to find a mate in Mate moves, or
+// @defaultDepth if there's no limit at all.
+func (l *SearchLimits) MaxDepth(defaultDepth int) int {
+	if l.Depth > 0 {
+		return l.Depth
+	}
+	if l.Mate > 0 {
+		return 2*l.Mate - 1
+	}
+	return defaultDepth
+}
+
+// Returns whether @score is a mate that satisfies the mate limit, i.e. a
+// mate in Mate moves or fewer, in which case we can stop searching.
+func (l *SearchLimits) IsMateFound(score Score) bool {
+	return l.Mate > 0 && score.IsMateInNOrBetter(2*l.Mate-1)
+}
+
+// Returns whether @move may be played at the root. The colour of promotion
+// pieces is ignored, because UCI always uses lowercase letters.
+func (l *SearchLimits) IsSearchMove(move *Move) bool {
+	if l == nil || len(l.SearchMoves) == 0 {
+		return true
+	}
+	for _, m := range l.SearchMoves {
+		if m.From == move.From && m.To == move.To && m.Promote.ToNormalizedPiece() == move.Promote.ToNormalizedPiece() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the moves that may be played at the root.
+func (l *SearchLimits) FilterMoves(moves []*Move) []*Move {
+	result := []*Move{}
+	for _, move := range moves {
+		if l.IsSearchMove(move) {
+			result = append(result, move)
+		}
+	}
+	return result
+}
+
+// Returns an error if we were asked to search only some moves, but none of
+// them can be played in @game, because then there's nothing to search.
+func (l *SearchLimits) CheckSearchMoves(game *Game) error {
+	if l == nil || len(l.SearchMoves) == 0 || len(l.FilterMoves(game.ValidMoves())) > 0 {
+		return nil
+	}
+	return fmt.Errorf("None of the searchmoves can be played: %s", Line(l.SearchMoves))
+}
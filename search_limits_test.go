@@ -0,0 +1,159 @@
+package chess_engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseSearchLimits(t *testing.T) {
+	limits, err := ParseSearchLimits(strings.Split("wtime 60000 btime 50000 winc 1000 binc 2000 movestogo 20", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.WhiteTime != 60*time.Second || limits.BlackTime != 50*time.Second {
+		t.Errorf("Unexpected clock: %v %v", limits.WhiteTime, limits.BlackTime)
+	}
+	if limits.WhiteIncrement != time.Second || limits.BlackIncrement != 2*time.Second {
+		t.Errorf("Unexpected increment: %v %v", limits.WhiteIncrement, limits.BlackIncrement)
+	}
+	if limits.MovesToGo != 20 {
+		t.Errorf("Expected movestogo 20, got %d", limits.MovesToGo)
+	}
+	remaining, inc := limits.Clock(Black)
+	if remaining != 50*time.Second || inc != 2*time.Second {
+		t.Errorf("Unexpected clock for black: %v %v", remaining, inc)
+	}
+	if !limits.IsTimed(White) {
+		t.Errorf("Expected a timed search")
+	}
+}
+
+func Test_ParseSearchLimits_other_limits(t *testing.T) {
+	limits, err := ParseSearchLimits(strings.Split("depth 5 nodes 1000 mate 3 movetime 200 infinite", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.Depth != 5 || limits.Nodes != 1000 || limits.Mate != 3 {
+		t.Errorf("Unexpected limits: %v", limits)
+	}
+	if limits.MoveTime != 200*time.Millisecond {
+		t.Errorf("Expected movetime 200ms, got %v", limits.MoveTime)
+	}
+	if !limits.Infinite || limits.IsTimed(White) {
+		t.Errorf("Expected an infinite search")
+	}
+}
+
+func Test_SearchLimits_MaxDepth(t *testing.T) {
+	cases := []struct {
+		limits   *SearchLimits
+		expected int
+	}{
+		{&SearchLimits{}, 4},
+		{&SearchLimits{Depth: 2}, 2},
+		{&SearchLimits{Mate: 2}, 3},
+		{&SearchLimits{Depth: 5, Mate: 1}, 5},
+	}
+	for _, c := range cases {
+		if depth := c.limits.MaxDepth(4); depth != c.expected {
+			t.Errorf("Expecting depth %d for %s, got %d", c.expected, c.limits, depth)
+		}
+	}
+}
+
+func Test_SearchLimits_IsMateFound(t *testing.T) {
+	limits := &SearchLimits{Mate: 2}
+	if !limits.IsMateFound(Mate-3) || !limits.IsMateFound(Mate-1) {
+		t.Errorf("Expecting mates in two moves or fewer to be found")
+	}
+	if limits.IsMateFound(Mate-5) || limits.IsMateFound(500) {
+		t.Errorf("Expecting a mate in three or a normal score not to be found")
+	}
+	if NewSearchLimits().IsMateFound(Mate - 1) {
+		t.Errorf("Expecting no mate to be found without a mate limit")
+	}
+}
+
+func Test_ParseSearchLimits_ignores_unknown_tokens(t *testing.T) {
+	limits, err := ParseSearchLimits(strings.Split("ponder searchmoves e2e4 depth 3", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.Depth != 3 {
+		t.Errorf("Expected depth 3, got %d", limits.Depth)
+	}
+	if !limits.Ponder {
+		t.Errorf("Expected a ponder search")
+	}
+}
+
+func Test_SearchLimits_String(t *testing.T) {
+	if NewSearchLimits().String() != "none" {
+		t.Errorf("Expected none, got %s", NewSearchLimits().String())
+	}
+	args := "ponder wtime 1000 btime 2000 movestogo 10 depth 3 searchmoves e2e4 d2d4"
+	limits, err := ParseSearchLimits(strings.Split(args, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.String() != args {
+		t.Errorf("Expected '%s', got '%s'", args, limits.String())
+	}
+}
+
+func Test_ParseSearchLimits_errors(t *testing.T) {
+	for _, args := range []string{"depth", "wtime abc", "nodes 10 movetime"} {
+		if _, err := ParseSearchLimits(strings.Split(args, " ")); err == nil {
+			t.Errorf("Expected an error for '%s'", args)
+		}
+	}
+}
+
+func Test_ParseSearchLimits_searchmoves(t *testing.T) {
+	limits, err := ParseSearchLimits(strings.Split("searchmoves e2e4 d2d4 e7e8q depth 3", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limits.SearchMoves) != 3 {
+		t.Fatalf("Expected 3 search moves, got %v", limits.SearchMoves)
+	}
+	if limits.Depth != 3 {
+		t.Errorf("Expected depth 3, got %d", limits.Depth)
+	}
+	if !limits.IsSearchMove(NewMove(E2, E4)) || limits.IsSearchMove(NewMove(G1, F3)) {
+		t.Errorf("Expected e2e4, but not g1f3 to be a search move")
+	}
+	if !limits.IsSearchMove(&Move{E7, E8, WhiteQueen}) || limits.IsSearchMove(&Move{E7, E8, WhiteKnight}) {
+		t.Errorf("Expected e7e8q, but not e7e8n to be a search move")
+	}
+	moves := limits.FilterMoves([]*Move{NewMove(G1, F3), NewMove(D2, D4)})
+	if len(moves) != 1 || moves[0].String() != "d2d4" {
+		t.Errorf("Expected only d2d4, got %v", moves)
+	}
+	if _, err := ParseSearchLimits([]string{"searchmoves", "depth", "3"}); err == nil {
+		t.Errorf("Expected an error for searchmoves without moves")
+	}
+	if !NewSearchLimits().IsSearchMove(NewMove(G1, F3)) {
+		t.Errorf("Expected every move to be a search move without searchmoves")
+	}
+}
+
+func Test_SearchLimits_CheckSearchMoves(t *testing.T) {
+	game, err := ParseFEN(StartingPositionFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := NewSearchLimits()
+	if err := limits.CheckSearchMoves(game); err != nil {
+		t.Errorf("Expected no error without searchmoves, got %s", err)
+	}
+	limits.SearchMoves = []*Move{NewMove(E2, E5), NewMove(G1, F3)}
+	if err := limits.CheckSearchMoves(game); err != nil {
+		t.Errorf("Expected no error when one of the searchmoves can be played, got %s", err)
+	}
+	limits.SearchMoves = []*Move{NewMove(E2, E5)}
+	if err := limits.CheckSearchMoves(game); err == nil {
+		t.Errorf("Expected an error when none of the searchmoves can be played")
+	}
+}
@@ -1,16 +1,17 @@
 package chess_engine
 
-// Keeping tracking of positions that have already been seen.
-type SeenMap map[string]bool
+// Keeping tracking of positions that have already been seen, keyed by their
+// Zobrist hash.
+type SeenMap map[uint64]bool
 
 func NewSeenMap() SeenMap {
-	return map[string]bool{}
+	return map[uint64]bool{}
 }
 
 func (s SeenMap) Seen(g *Game) bool {
-	return s[g.FENString()]
+	return s[g.Hash]
 }
 
 func (s SeenMap) Set(g *Game) {
-	s[g.FENString()] = true
+	s[g.Hash] = true
 }
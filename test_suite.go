@@ -0,0 +1,126 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The result of running an engine on a position from a test suite.
+type TestSuiteResult struct {
+	EPD      *EPD
+	BestMove *Move
+	Solved   bool
+	// How long it took before the engine settled on a correct move, i.e.
+	// the time of the first info line after which it didn't change its
+	// mind anymore. Only set when the position was solved.
+	SolveTime time.Duration
+	Nodes     int
+}
+
+// Returns whether @move solves the position: it has to be one of the best
+// moves (bm) if there are any, and it can't be one of the moves to avoid
+// (am).
+func (e *EPD) IsSolvedBy(move *Move) (bool, error) {
+	best, err := e.BestMoves()
+	if err != nil {
+		return false, err
+	}
+	avoid, err := e.AvoidMoves()
+	if err != nil {
+		return false, err
+	}
+	if best == nil && avoid == nil {
+		return false, fmt.Errorf("Position %s has no bm or am operation", e.ID())
+	}
+	if move == nil {
+		return false, nil
+	}
+	for _, m := range avoid {
+		if *m == *move {
+			return false, nil
+		}
+	}
+	if best == nil {
+		return true, nil
+	}
+	for _, m := range best {
+		if *m == *move {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Searches the position with @engine and checks the move it comes up
+// with.
+func RunTestSuitePosition(engine Engine, epd *EPD, limits *SearchLimits) (*TestSuiteResult, error) {
+	// Make sure the position has bm or am before we start searching
+	if _, err := epd.IsSolvedBy(nil); err != nil {
+		return nil, err
+	}
+	result := &TestSuiteResult{EPD: epd}
+	output := make(chan string, 100)
+	startTime := time.Now()
+	engine.SetPosition(epd.Position)
+	engine.Start(output, limits)
+
+	// When the engine last changed its mind to a correct move.
+	var solvedAt *time.Duration
+	for line := range output {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "bestmove" {
+			if len(fields) > 1 {
+				if move, err := ParseMove(fields[1]); err == nil {
+					result.BestMove = epd.Position.FindValidMove(move)
+				}
+			}
+			break
+		}
+		// Only the best line counts when the engine reports more than one
+		if fields[0] != "info" || (infoField(fields, "multipv") != "" && infoField(fields, "multipv") != "1") {
+			continue
+		}
+		if nodes, err := strconv.Atoi(infoField(fields, "nodes")); err == nil {
+			result.Nodes = nodes
+		}
+		move, err := ParseMove(infoField(fields, "pv"))
+		if err != nil {
+			continue
+		}
+		solved, _ := epd.IsSolvedBy(epd.Position.FindValidMove(move))
+		if !solved {
+			solvedAt = nil
+		} else if solvedAt == nil {
+			elapsed := time.Since(startTime)
+			solvedAt = &elapsed
+		}
+	}
+	solved, err := epd.IsSolvedBy(result.BestMove)
+	if err != nil {
+		return nil, err
+	}
+	result.Solved = solved
+	if solved {
+		result.SolveTime = time.Since(startTime)
+		if solvedAt != nil {
+			result.SolveTime = *solvedAt
+		}
+	}
+	return result, nil
+}
+
+// Returns the value following @name in a UCI info line, e.g. the first move
+// of the pv, or an empty string if it's not there.
+func infoField(fields []string, name string) string {
+	for i := 1; i+1 < len(fields); i++ {
+		if fields[i] == name {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package chess_engine
+
+import (
+	"testing"
+)
+
+func Test_EPD_IsSolvedBy(t *testing.T) {
+	unit, err := ParseEPD(`r3k2r/8/8/8/8/8/8/R3K2R w KQkq - bm O-O Rb1; am Ra8+;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"e1g1": true,
+		"a1b1": true,
+		"a1a8": false,
+		"e1d1": false,
+	}
+	for move, expected := range cases {
+		if solved, err := unit.IsSolvedBy(MustParseMove(move)); err != nil || solved != expected {
+			t.Errorf("Expecting %v for %s, got %v (%v)", expected, move, solved, err)
+		}
+	}
+	unit, err = ParseEPD(`r3k2r/8/8/8/8/8/8/R3K2R w KQkq - am Ra8+;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solved, _ := unit.IsSolvedBy(MustParseMove("e1d1")); !solved {
+		t.Errorf("Expecting any move but the one to avoid to solve the position")
+	}
+	unit, err = ParseEPD(`r3k2r/8/8/8/8/8/8/R3K2R w KQkq - id "nothing to solve";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unit.IsSolvedBy(MustParseMove("e1d1")); err == nil {
+		t.Errorf("Expecting an error without bm or am")
+	}
+}
+
+func Test_RunTestSuitePosition(t *testing.T) {
+	engine := NewAlphaBetaEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	limits := NewSearchLimits()
+	limits.Depth = 2
+	cases := map[string]bool{
+		`7k/8/6K1/8/8/8/8/5Q2 w - - bm Qf8#; id "mate";`:    true,
+		`7k/8/6K1/8/8/8/8/5Q2 w - - am Qf8#; id "no mate";`: false,
+	}
+	for record, expected := range cases {
+		epd, err := ParseEPD(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := RunTestSuitePosition(engine, epd, limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Solved != expected || result.BestMove == nil {
+			t.Errorf("Expecting solved to be %v for %s, got %v", expected, epd.ID(), result.BestMove)
+		}
+		if result.Nodes == 0 {
+			t.Errorf("Expecting the nodes to be reported")
+		}
+	}
+}
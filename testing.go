@@ -1,31 +1,275 @@
 package chess_engine
 
-import "fmt"
+import (
+	"context"
+	"sort"
+	"sync"
+	"unsafe"
+)
 
+// The counts that perft tables usually list for every depth. Everything but
+// the nodes is counted for the moves (captures, castles, ...) or positions
+// (checks, mates) at the last ply.
+type PerftResult struct {
+	Nodes      int
+	Captures   int
+	EnPassants int
+	Castles    int
+	Promotions int
+	Checks     int
+	Mates      int
+}
+
+func (r *PerftResult) Add(other PerftResult) {
+	r.Nodes += other.Nodes
+	r.Captures += other.Captures
+	r.EnPassants += other.EnPassants
+	r.Castles += other.Castles
+	r.Promotions += other.Promotions
+	r.Checks += other.Checks
+	r.Mates += other.Mates
+}
+
+// The perft result for one of the moves in the root position.
+type PerftDivision struct {
+	Move *Move
+	PerftResult
+}
+
+// Counts the number of positions that can be reached in @maxdepth moves and
+// the number of them that are check.
 func Perft(game *Game, maxdepth int) (int, int) {
-	return perft(game, maxdepth, maxdepth)
+	result := PerftWithHash(game, maxdepth, nil)
+	return result.Nodes, result.Checks
 }
 
-func perft(game *Game, maxdepth, depth int) (int, int) {
+// Like Perft, but returns all the counts and can use a hash table to avoid
+// counting transpositions more than once.
+func PerftWithHash(game *Game, depth int, hash *PerftHashTable) PerftResult {
+	result, _ := perft(context.Background(), game, depth, hash)
+	return result
+}
 
+// Does the counting for PerftWithHash. Returns false if @ctx is done before
+// we've counted everything, in which case the result is incomplete.
+func perft(ctx context.Context, game *Game, depth int, hash *PerftHashTable) (PerftResult, bool) {
 	if depth == 0 {
-		c := 0
+		result := PerftResult{Nodes: 1}
 		if game.InCheck() {
-			c = 1
+			result.Checks = 1
 		}
-		return 1, c
-	}
-	moves := game.NextGames()
-	checks := 0
-	nodes := 0
-	for _, m := range moves {
-		n, c := perft(m, maxdepth, depth-1)
-		nodes += n
-		checks += c
-		if depth == maxdepth {
-			fmt.Printf("%s: %d\n", m.Line[0], n)
+		return result, true
+	}
+	if result, ok := hash.Probe(game.Hash, depth); ok {
+		return result, true
+	}
+	if ctx.Err() != nil {
+		return PerftResult{}, false
+	}
+	result := PerftResult{}
+	for _, next := range game.NextGames() {
+		if depth == 1 {
+			result.Add(perftLeaf(game, next))
+		} else {
+			nextResult, ok := perft(ctx, next, depth-1, hash)
+			if !ok {
+				return result, false
+			}
+			result.Add(nextResult)
 		}
 	}
+	// Free up the memory
 	game.nextGames = nil
-	return nodes, checks
+	hash.Store(game.Hash, depth, result)
+	return result, true
+}
+
+// Classifies the move from @game to @next.
+func perftLeaf(game, next *Game) PerftResult {
+	move := next.Line[len(next.Line)-1]
+	piece := game.Board[move.From]
+	result := PerftResult{Nodes: 1}
+	if game.IsCapture(move) {
+		result.Captures = 1
+	}
+	if move.GetEnPassantCapture(piece, game.EnPassantVulnerable) != nil {
+		result.EnPassants = 1
+	}
+	if move.GetRookCastlesMove(piece) != nil {
+		result.Castles = 1
+	}
+	if move.Promote != NoPiece {
+		result.Promotions = 1
+	}
+	if next.InCheck() {
+		result.Checks = 1
+		if next.IsMate() {
+			result.Mates = 1
+		}
+	}
+	return result
+}
+
+// Counts the positions like PerftWithHash, but splits the moves in the
+// root position over @workers goroutines.
+func PerftParallel(game *Game, depth, workers int, hash *PerftHashTable) PerftResult {
+	if depth < 1 {
+		return PerftWithHash(game, depth, hash)
+	}
+	result := PerftResult{}
+	for _, division := range PerftDivideParallel(game, depth, workers, hash) {
+		result.Add(division.PerftResult)
+	}
+	return result
+}
+
+// Returns the perft result for every move in the root position, ordered
+// by move. This makes it possible to find bugs in the move generator by
+// comparing the numbers with another engine's.
+func PerftDivide(game *Game, depth int, hash *PerftHashTable) []*PerftDivision {
+	return PerftDivideParallel(game, depth, 1, hash)
+}
+
+// Like PerftDivide, but the moves are divided over @workers goroutines.
+//
+// The Games cache their valid moves, next games and scores without any
+// locking, which is fine because every worker gets its own subtree: the
+// root position is expanded before the workers start and they never touch
+// it or each other's Games. The hash table is the only thing they share.
+func PerftDivideParallel(game *Game, depth, workers int, hash *PerftHashTable) []*PerftDivision {
+	result := []*PerftDivision{}
+	if depth < 1 {
+		return result
+	}
+	nextGames := game.NextGames()
+	for _, next := range nextGames {
+		result = append(result, &PerftDivision{
+			Move: next.Line[len(next.Line)-1],
+		})
+	}
+	if workers <= 1 {
+		for i, division := range result {
+			division.PerftResult, _ = perftDivision(context.Background(), game, nextGames[i], depth, hash)
+		}
+	} else {
+		jobs := make(chan int, len(result))
+		for i := range result {
+			jobs <- i
+		}
+		close(jobs)
+		wg := sync.WaitGroup{}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					result[i].PerftResult, _ = perftDivision(context.Background(), game, nextGames[i], depth, hash)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	sortPerftDivisions(result)
+	return result
+}
+
+// Like PerftDivide, but stops counting when @ctx is done. In that case it
+// returns false and only the moves that were counted completely.
+func PerftDivideContext(ctx context.Context, game *Game, depth int, hash *PerftHashTable) ([]*PerftDivision, bool) {
+	result := []*PerftDivision{}
+	if depth < 1 {
+		return result, true
+	}
+	for _, next := range game.NextGames() {
+		counts, ok := perftDivision(ctx, game, next, depth, hash)
+		if !ok {
+			sortPerftDivisions(result)
+			return result, false
+		}
+		result = append(result, &PerftDivision{
+			Move:        next.Line[len(next.Line)-1],
+			PerftResult: counts,
+		})
+	}
+	sortPerftDivisions(result)
+	return result, true
+}
+
+func sortPerftDivisions(divisions []*PerftDivision) {
+	sort.Slice(divisions, func(i, j int) bool {
+		return divisions[i].Move.String() < divisions[j].Move.String()
+	})
+}
+
+// The perft result for the root move that leads to @next.
+func perftDivision(ctx context.Context, game, next *Game, depth int, hash *PerftHashTable) (PerftResult, bool) {
+	if depth == 1 {
+		return perftLeaf(game, next), true
+	}
+	return perft(ctx, next, depth-1, hash)
+}
+
+type PerftHashEntry struct {
+	Hash   uint64
+	Depth  int
+	Result PerftResult
+}
+
+// A hash table for perft results, so that positions that can be reached in
+// different ways are only counted once. A nil table is never used. It can be
+// shared by the workers of a parallel perft.
+//
+// Every entry is guarded by one of a fixed number of locks, picked by its
+// index, so workers only wait for each other when they use entries that share
+// a lock. Lockless entries would be faster still, but the result is larger
+// than a word, so they'd need a checksum to detect torn writes.
+type PerftHashTable struct {
+	Entries []PerftHashEntry
+	locks   [perftHashLocks]sync.Mutex
+}
+
+// The number of locks for the entries of a PerftHashTable.
+const perftHashLocks = 64
+
+func NewPerftHashTable(megabytes int) *PerftHashTable {
+	if megabytes < 1 {
+		megabytes = 1
+	}
+	size := megabytes * 1024 * 1024 / int(unsafe.Sizeof(PerftHashEntry{}))
+	return &PerftHashTable{
+		Entries: make([]PerftHashEntry, size),
+	}
+}
+
+// Returns the result for the position at the given depth, or false if
+// there isn't one.
+func (t *PerftHashTable) Probe(hash uint64, depth int) (PerftResult, bool) {
+	if t == nil || hash == 0 {
+		return PerftResult{}, false
+	}
+	index := hash % uint64(len(t.Entries))
+	lock := &t.locks[index%perftHashLocks]
+	lock.Lock()
+	entry := t.Entries[index]
+	lock.Unlock()
+	if entry.Hash != hash || entry.Depth != depth {
+		return PerftResult{}, false
+	}
+	return entry.Result, true
+}
+
+// Stores a result, always replacing the existing entry.
+func (t *PerftHashTable) Store(hash uint64, depth int, result PerftResult) {
+	if t == nil {
+		return
+	}
+	index := hash % uint64(len(t.Entries))
+	lock := &t.locks[index%perftHashLocks]
+	lock.Lock()
+	t.Entries[index] = PerftHashEntry{
+		Hash:   hash,
+		Depth:  depth,
+		Result: result,
+	}
+	lock.Unlock()
 }
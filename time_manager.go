@@ -0,0 +1,152 @@
+package chess_engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// The number of moves we assume are left in the game when the GUI
+	// doesn't tell us with movestogo.
+	DefaultMovesToGo = 30
+	// Time we keep in reserve to account for communication with the GUI.
+	MoveOverhead = 50 * time.Millisecond
+	// We always want to have some time to search.
+	MinimumSearchTime = 10 * time.Millisecond
+)
+
+// The TimeManager decides how much of the clock we can spend on a move. The
+// soft limit is the time after which we shouldn't start a new iteration,
+// because it's unlikely to finish. The hard limit is when the search has to
+// stop, no matter what. A zero limit means there is no limit.
+//
+// When we're pondering the clock doesn't start running until the GUI tells
+// us that the opponent played the move we were pondering on (ponderhit).
+type TimeManager struct {
+	StartTime time.Time
+	SoftLimit time.Duration
+	HardLimit time.Duration
+	Infinite  bool
+
+	mutex     sync.Mutex
+	pondering bool
+	ponderHit chan struct{}
+	cancel    context.CancelFunc
+}
+
+func NewTimeManager(limits *SearchLimits, color Color) *TimeManager {
+	tm := &TimeManager{
+		StartTime: time.Now(),
+		ponderHit: make(chan struct{}),
+	}
+	if limits == nil {
+		return tm
+	}
+	tm.Infinite = limits.Infinite
+	tm.pondering = limits.Ponder
+	if !limits.IsTimed(color) {
+		return tm
+	}
+	if limits.MoveTime > 0 {
+		tm.SoftLimit = atLeast(limits.MoveTime-MoveOverhead, MinimumSearchTime)
+		tm.HardLimit = tm.SoftLimit
+		return tm
+	}
+	remaining, increment := limits.Clock(color)
+	movesToGo := limits.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = DefaultMovesToGo
+	}
+	available := atLeast(remaining-MoveOverhead, MinimumSearchTime)
+
+	tm.SoftLimit = available/time.Duration(movesToGo) + increment*3/4
+	tm.HardLimit = tm.SoftLimit * 3
+
+	// Never use more than a quarter of what's left on the clock in one
+	// move, unless this is the last move before the time control.
+	maxTime := available / 4
+	if movesToGo == 1 {
+		maxTime = available
+	}
+	if tm.HardLimit > maxTime {
+		tm.HardLimit = maxTime
+	}
+	if tm.SoftLimit > tm.HardLimit {
+		tm.SoftLimit = tm.HardLimit
+	}
+	tm.SoftLimit = atLeast(tm.SoftLimit, MinimumSearchTime)
+	tm.HardLimit = atLeast(tm.HardLimit, MinimumSearchTime)
+	return tm
+}
+
+// Returns a context that gets cancelled when the hard limit is reached. If
+// we're pondering, the hard limit only starts counting after PonderHit.
+func (tm *TimeManager) Start(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	tm.cancel = cancel
+	if !tm.pondering {
+		tm.startClock()
+	}
+	return ctx, cancel
+}
+
+func (tm *TimeManager) startClock() {
+	if tm.HardLimit > 0 {
+		time.AfterFunc(tm.HardLimit-time.Since(tm.StartTime), tm.cancel)
+	}
+}
+
+// The opponent played the move we were pondering on, so from now on the
+// search is a normal timed search.
+func (tm *TimeManager) PonderHit() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if !tm.pondering {
+		return
+	}
+	tm.pondering = false
+	tm.StartTime = time.Now()
+	if tm.cancel != nil {
+		tm.startClock()
+	}
+	close(tm.ponderHit)
+}
+
+func (tm *TimeManager) IsPondering() bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return tm.pondering
+}
+
+// Returns whether there's enough time left to start another iteration.
+func (tm *TimeManager) CanStartIteration() bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return tm.pondering || tm.SoftLimit == 0 || time.Since(tm.StartTime) < tm.SoftLimit
+}
+
+// When we're pondering, or searching infinitely, the GUI doesn't want to
+// hear about our best move until it tells us to stop (or, when pondering,
+// until the ponderhit), so once the search is done we wait for that.
+func (tm *TimeManager) WaitForStop(ctx context.Context) {
+	if tm.Infinite {
+		<-ctx.Done()
+		return
+	}
+	if tm.IsPondering() {
+		select {
+		case <-ctx.Done():
+		case <-tm.ponderHit:
+		}
+	}
+}
+
+func atLeast(d, min time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	return d
+}
@@ -0,0 +1,117 @@
+package chess_engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_TimeManager_untimed(t *testing.T) {
+	tm := NewTimeManager(NewSearchLimits(), White)
+	if tm.SoftLimit != 0 || tm.HardLimit != 0 {
+		t.Errorf("Expected no limits, got %v %v", tm.SoftLimit, tm.HardLimit)
+	}
+	if !tm.CanStartIteration() {
+		t.Errorf("Expected to be able to start an iteration")
+	}
+}
+
+func Test_TimeManager_movetime(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.MoveTime = time.Second
+	tm := NewTimeManager(limits, Black)
+	if tm.SoftLimit != time.Second-MoveOverhead || tm.HardLimit != tm.SoftLimit {
+		t.Errorf("Unexpected limits %v %v", tm.SoftLimit, tm.HardLimit)
+	}
+}
+
+func Test_TimeManager_clock(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.WhiteTime = 60*time.Second + MoveOverhead
+	limits.WhiteIncrement = time.Second
+	limits.BlackTime = time.Second
+	tm := NewTimeManager(limits, White)
+	expected := 2*time.Second + 750*time.Millisecond
+	if tm.SoftLimit != expected {
+		t.Errorf("Expected soft limit %v, got %v", expected, tm.SoftLimit)
+	}
+	if tm.HardLimit != 3*expected {
+		t.Errorf("Expected hard limit %v, got %v", 3*expected, tm.HardLimit)
+	}
+}
+
+func Test_TimeManager_clock_never_uses_more_than_a_quarter(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.BlackTime = 4*time.Second + MoveOverhead
+	limits.BlackIncrement = 10 * time.Second
+	tm := NewTimeManager(limits, Black)
+	if tm.HardLimit != time.Second || tm.SoftLimit != time.Second {
+		t.Errorf("Unexpected limits %v %v", tm.SoftLimit, tm.HardLimit)
+	}
+}
+
+func Test_TimeManager_clock_last_move_before_time_control(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.WhiteTime = 4*time.Second + MoveOverhead
+	limits.MovesToGo = 1
+	tm := NewTimeManager(limits, White)
+	if tm.SoftLimit != 4*time.Second || tm.HardLimit != 4*time.Second {
+		t.Errorf("Unexpected limits %v %v", tm.SoftLimit, tm.HardLimit)
+	}
+}
+
+func Test_TimeManager_low_on_time(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.WhiteTime = time.Millisecond
+	tm := NewTimeManager(limits, White)
+	if tm.SoftLimit != MinimumSearchTime || tm.HardLimit != MinimumSearchTime {
+		t.Errorf("Unexpected limits %v %v", tm.SoftLimit, tm.HardLimit)
+	}
+}
+
+func Test_TimeManager_ponder(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.MoveTime = MoveOverhead + 20*time.Millisecond
+	limits.Ponder = true
+	tm := NewTimeManager(limits, White)
+	ctx, cancel := tm.Start(context.Background())
+	defer cancel()
+
+	time.Sleep(50 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Errorf("Expecting the clock not to run while pondering")
+	}
+	if !tm.CanStartIteration() || !tm.IsPondering() {
+		t.Errorf("Expecting to keep searching while pondering")
+	}
+
+	tm.PonderHit()
+	if tm.IsPondering() {
+		t.Errorf("Expecting to stop pondering after a ponderhit")
+	}
+	tm.WaitForStop(ctx)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expecting the clock to run after a ponderhit")
+	}
+}
+
+func Test_TimeManager_WaitForStop_infinite(t *testing.T) {
+	limits := NewSearchLimits()
+	limits.Infinite = true
+	tm := NewTimeManager(limits, White)
+	ctx, cancel := tm.Start(context.Background())
+	stopped := make(chan bool)
+	go func() {
+		tm.WaitForStop(ctx)
+		stopped <- true
+	}()
+	select {
+	case <-stopped:
+		t.Errorf("Expecting to wait until we're stopped")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	<-stopped
+}
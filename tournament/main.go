@@ -130,6 +130,8 @@ func (e *Engine) ReadUntilBestMove(fen *chess_engine.Game) *chess_engine.Move {
 
 var Engines = []*Engine{
 	NewEngine("bs-engine-everything-mobility", "bs-engine", []string{"--naive-material", "--mobility", "--pawn-structure", "--tempo"}),
+	NewEngine("bs-engine-alpha-beta-everything-mobility", "bs-engine", []string{"--alpha-beta", "--naive-material", "--mobility", "--pawn-structure", "--tempo"}),
+	NewEngine("bs-engine-alpha-beta-space-and-material", "bs-engine", []string{"--alpha-beta", "--space", "--naive-material"}),
 	NewEngine("stockfish", "stockfish", nil),
 	NewEngine("bs-engine-everything-space", "bs-engine", []string{"--space", "--naive-material", "--pawn-structure", "--tempo"}),
 	NewEngine("bs-engine-everything", "bs-engine", []string{"--space", "--naive-material", "--mobility", "--pawn-structure", "--tempo"}),
@@ -250,6 +252,12 @@ func (t *Tournament) SetResult(game *Game, fen *chess_engine.Game, result GameRe
 	game.White.UpdateRating(result, game.Black.Rating, true)
 	game.Black.UpdateRating(result, game.White.Rating, false)
 
+	// The game either ended on the board, or because one of the engines
+	// crashed.
+	termination := "rules infraction"
+	if fen.IsMate() || fen.IsDraw() {
+		termination = "normal"
+	}
 	tags := chess_engine.PGNTags{
 		Event:  "bs-engine tournament",
 		Site:   "Camberwell",
@@ -258,6 +266,11 @@ func (t *Tournament) SetResult(game *Game, fen *chess_engine.Game, result GameRe
 		White:  game.White.Name,
 		Black:  game.Black.Name,
 		Result: game.Result.String(),
+		AdditionalTags: map[string]string{
+			// The engines search to a fixed depth instead of using a clock
+			"TimeControl": "-",
+			"Termination": termination,
+		},
 	}
 	gif := game.White.Name + "." + game.Black.Name + "." + tags.Date + ".gif"
 	fmt.Println("Writing", gif)
@@ -332,6 +345,7 @@ func (t *Tournament) Start() {
 				t.OutputStatus(game, fen)
 			}
 			if fen.IsDraw() {
+				fmt.Println(DrawReason(fen))
 				t.SetResult(game, fen, Draw)
 			} else if fen.IsMate() {
 				t.SetResult(game, fen, WhiteWins)
@@ -355,6 +369,7 @@ func (t *Tournament) Start() {
 					t.OutputStatus(game, fen)
 				}
 				if fen.IsDraw() {
+					fmt.Println(DrawReason(fen))
 					t.SetResult(game, fen, Draw)
 				} else if fen.IsMate() {
 					t.SetResult(game, fen, BlackWins)
@@ -366,6 +381,17 @@ func (t *Tournament) Start() {
 	}
 }
 
+func DrawReason(fen *chess_engine.Game) string {
+	if fen.HalfmoveClock >= 100 {
+		return "Draw by the fifty move rule"
+	} else if fen.IsThreefoldRepetition() {
+		return "Draw by threefold repetition"
+	} else if fen.IsInsufficientMaterial() {
+		return "Draw by insufficient material"
+	}
+	return "Draw by stalemate"
+}
+
 func (t *Tournament) OutputStatus(game *Game, fen *chess_engine.Game) {
 	toPlay := "White"
 	engineName := game.White.Name
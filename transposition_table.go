@@ -0,0 +1,134 @@
+package chess_engine
+
+import (
+	"unsafe"
+)
+
+type BoundType uint8
+
+const (
+	// The score is the exact score for the position
+	ExactBound BoundType = iota
+	// The score is a lower bound; the position is at least this good
+	LowerBound
+	// The score is an upper bound; the position is at most this good
+	UpperBound
+)
+
+const DefaultHashSize = 16
+
+// Mate scores within this many plies of Mate are adjusted when they are
+// stored in, and read from, the transposition table.
+const maxMatePlies = 1000
+
+type TranspositionEntry struct {
+	Hash     uint64
+	BestMove *Move
+	Score    Score
+	Depth    int16
+	Bound    BoundType
+	Age      uint8
+}
+
+// The transposition table remembers search results by Zobrist hash (see
+// zobrist.go), so that positions that we reach through different move orders,
+// or that we've already looked at in a previous search, don't have to be
+// searched again. The table has a fixed size and when two positions map onto
+// the same slot we keep the one that was searched the deepest, unless the
+// stored entry is from an older search.
+type TranspositionTable struct {
+	Entries []TranspositionEntry
+	Age     uint8
+}
+
+// Creates a new transposition table that uses roughly @megabytes of memory.
+func NewTranspositionTable(megabytes int) *TranspositionTable {
+	if megabytes < 1 {
+		megabytes = 1
+	}
+	size := megabytes * 1024 * 1024 / int(unsafe.Sizeof(TranspositionEntry{}))
+	return &TranspositionTable{
+		Entries: make([]TranspositionEntry, size),
+	}
+}
+
+// Returns the entry for the given hash, or nil if there isn't one.
+func (t *TranspositionTable) Probe(hash uint64) *TranspositionEntry {
+	entry := &t.Entries[hash%uint64(len(t.Entries))]
+	if hash == 0 || entry.Hash != hash {
+		return nil
+	}
+	return entry
+}
+
+// Stores a search result. The score should be from the perspective of the
+// side to move and @ply is the distance from the root of the search, which
+// is used to make mate scores relative to the stored position.
+func (t *TranspositionTable) Store(hash uint64, ply, depth int, score Score, bound BoundType, bestMove *Move) {
+	entry := &t.Entries[hash%uint64(len(t.Entries))]
+	if entry.Age == t.Age && int(entry.Depth) > depth {
+		return
+	}
+	if bestMove == nil && entry.Hash == hash {
+		bestMove = entry.BestMove
+	}
+	entry.Hash = hash
+	entry.BestMove = bestMove
+	entry.Score = scoreToTranspositionTable(score, ply)
+	entry.Depth = int16(depth)
+	entry.Bound = bound
+	entry.Age = t.Age
+}
+
+// Returns the stored score for an entry that was probed at distance @ply from
+// the root.
+func (e *TranspositionEntry) GetScore(ply int) Score {
+	return scoreFromTranspositionTable(e.Score, ply)
+}
+
+// Marks the start of a new search, so that entries from previous searches
+// can be replaced.
+func (t *TranspositionTable) NewSearch() {
+	t.Age++
+}
+
+func (t *TranspositionTable) Clear() {
+	for i := range t.Entries {
+		t.Entries[i] = TranspositionEntry{}
+	}
+	t.Age = 0
+}
+
+// Returns how full the table is in permille, as used by the UCI hashfull
+// info. Only the first thousand entries are sampled.
+func (t *TranspositionTable) Hashfull() int {
+	used := 0
+	sample := 1000
+	if len(t.Entries) < sample {
+		sample = len(t.Entries)
+	}
+	for i := 0; i < sample; i++ {
+		if t.Entries[i].Hash != 0 && t.Entries[i].Age == t.Age {
+			used++
+		}
+	}
+	return used * 1000 / sample
+}
+
+func scoreToTranspositionTable(score Score, ply int) Score {
+	if score >= Mate-maxMatePlies && score <= Mate {
+		return score + Score(ply)
+	} else if score <= OpponentMate+maxMatePlies && score >= OpponentMate {
+		return score - Score(ply)
+	}
+	return score
+}
+
+func scoreFromTranspositionTable(score Score, ply int) Score {
+	if score >= Mate-maxMatePlies && score <= Mate {
+		return score - Score(ply)
+	} else if score <= OpponentMate+maxMatePlies && score >= OpponentMate {
+		return score + Score(ply)
+	}
+	return score
+}
@@ -0,0 +1,85 @@
+package chess_engine
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_TranspositionTable_Store(t *testing.T) {
+	unit := NewTranspositionTable(1)
+	if unit.Probe(1234) != nil {
+		t.Fatal("Not expecting an entry in an empty table")
+	}
+	unit.Store(1234, 0, 3, 150, ExactBound, NewMove(E2, E4))
+	entry := unit.Probe(1234)
+	if entry == nil {
+		t.Fatal("Expecting an entry")
+	}
+	if entry.GetScore(0) != 150 || entry.Depth != 3 || entry.Bound != ExactBound || entry.BestMove != NewMove(E2, E4) {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+
+	// Shallower searches shouldn't replace deeper ones
+	unit.Store(1234, 0, 2, 100, LowerBound, NewMove(D2, D4))
+	if entry := unit.Probe(1234); entry.Depth != 3 || entry.BestMove != NewMove(E2, E4) {
+		t.Errorf("Not expecting a shallower search to replace the entry, got %v", entry)
+	}
+
+	// Unless they are from a newer search
+	unit.NewSearch()
+	unit.Store(1234, 0, 2, 100, LowerBound, NewMove(D2, D4))
+	if entry := unit.Probe(1234); entry.Depth != 2 || entry.BestMove != NewMove(D2, D4) {
+		t.Errorf("Expecting a newer search to replace the entry, got %v", entry)
+	}
+
+	// Different hashes that map onto the same slot
+	other := 1234 + uint64(len(unit.Entries))
+	if unit.Probe(other) != nil {
+		t.Errorf("Not expecting an entry for a different hash")
+	}
+
+	unit.Clear()
+	if unit.Probe(1234) != nil {
+		t.Errorf("Not expecting an entry after clearing the table")
+	}
+}
+
+func Test_TranspositionTable_mate_scores(t *testing.T) {
+	unit := NewTranspositionTable(1)
+	// Mate in 5 from the root, found at ply 2, is a mate in 3 from the
+	// stored position.
+	unit.Store(1234, 2, 3, Mate-5, ExactBound, nil)
+	entry := unit.Probe(1234)
+	if entry.GetScore(0) != Mate-3 {
+		t.Errorf("Expecting mate in 3, got %d", entry.GetScore(0))
+	}
+	if entry.GetScore(4) != Mate-7 {
+		t.Errorf("Expecting mate in 7, got %d", entry.GetScore(4))
+	}
+	unit.Store(5678, 2, 3, OpponentMate+5, ExactBound, nil)
+	if score := unit.Probe(5678).GetScore(0); score != OpponentMate+3 {
+		t.Errorf("Expecting to be mated in 3, got %d", score)
+	}
+}
+
+func Test_Engine_stores_best_line_in_TranspositionTable(t *testing.T) {
+	fen, err := ParseFEN("r1bq2r1/b4pk1/p1pp1p2/1p2pP2/1P2P1PB/3P4/1PPQ2P1/R3K2R w - - 0 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := NewBSEngine(3)
+	unit.AddEvaluator(NaiveMaterialEvaluator)
+	unit.SetPosition(fen)
+	bestmove := getBestMove(unit, 3*time.Second)
+	entry := unit.TranspositionTable.Probe(fen.Hash)
+	if entry == nil {
+		t.Fatal("Expecting the root position to be stored")
+	}
+	if entry.BestMove.String() != bestmove {
+		t.Errorf("Expecting best move %s to be stored, got %s", bestmove, entry.BestMove)
+	}
+	unit.NewGame()
+	if unit.TranspositionTable.Probe(fen.Hash) != nil {
+		t.Errorf("Expecting a new game to clear the transposition table")
+	}
+}
@@ -2,24 +2,23 @@ package chess_engine
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"strings"
-)
-
-type EngineOption uint8
-
-const (
-	SELDEPTH EngineOption = iota
+	"time"
 )
 
 type Engine interface {
 	SetPosition(*Game)
 	GetPosition() *Game
 	AddEvaluator(Evaluator)
-	Start(engineOutput chan string, maxNodes int, maxDepth int)
-	SetOption(EngineOption, int)
+	Start(engineOutput chan string, limits *SearchLimits)
+	SetOption(EngineOption, OptionValue) error
+	NewGame()
+	// Called when the opponent played the move we were pondering on.
+	PonderHit()
 	Stop()
 }
 
@@ -28,109 +27,323 @@ type UCI struct {
 	Author  string
 	LogFile string
 	Engine  Engine
+	Options []*UCIOption
+	// Where we write our responses to the GUI; stdout by default.
+	Output io.Writer
+	// When Debug is on we send extra diagnostics to the GUI.
+	Debug bool
+
+	log           *os.File
+	running       bool
+	searching     bool
+	searchStarted time.Time
+	// Stops the perft command we're running, if any. Perft counts as
+	// searching, so that we don't change the position while it's running.
+	cancelPerft context.CancelFunc
+	// Receives the result of the perft command when it's done.
+	perftDone chan *perftResult
 }
 
 func NewUCI(engineName, author string, engine Engine) *UCI {
-	return &UCI{
+	uci := &UCI{
 		Name:    engineName,
 		Author:  author,
-		LogFile: "/tmp/bsengine.log",
+		LogFile: DefaultLogFile,
 		Engine:  engine,
+		Output:  os.Stdout,
+	}
+	uci.Options = uci.defaultOptions()
+	return uci
+}
+
+// The options we advertise to the GUI. Besides the Evaluators option, which
+// takes a list of evaluator names, every evaluator also gets its own check
+// box.
+func (uci *UCI) defaultOptions() []*UCIOption {
+	engineOption := func(opt EngineOption) func(OptionValue) error {
+		return func(value OptionValue) error {
+			return uci.Engine.SetOption(opt, value)
+		}
+	}
+	options := []*UCIOption{
+		NewSpinOption("Depth", DefaultSelDepth, 1, 100, engineOption(SELDEPTH)),
+		NewSpinOption("Hash", DefaultHashSize, 1, 1024, engineOption(HASH)),
+		NewSpinOption("Threads", 1, 1, 1, engineOption(THREADS)),
+		NewSpinOption("MultiPV", 1, 1, MaxMultiPV, engineOption(MULTIPV)),
+		NewStringOption("Evaluators", "", uci.setEvaluators),
+		// We can always ponder, but the GUI expects this option to be
+		// there if we do.
+		NewCheckOption("Ponder", false, nil),
+		NewStringOption("Debug Log File", uci.LogFile, uci.setLogFile),
+	}
+	for _, named := range NamedEvaluators {
+		options = append(options, NewCheckOption(named.Name, false, uci.setEvaluatorCheckBoxes))
+	}
+	return options
+}
+
+// Returns the option with the given name, or nil if there isn't one. Option
+// names are case insensitive.
+func (uci *UCI) GetOption(name string) *UCIOption {
+	for _, option := range uci.Options {
+		if strings.EqualFold(option.Name, name) {
+			return option
+		}
+	}
+	return nil
+}
+
+func (uci *UCI) SetOption(name, value string) error {
+	option := uci.GetOption(name)
+	if option == nil {
+		return fmt.Errorf("Unknown option %s", name)
 	}
+	return option.Set(value)
 }
 
-// Reads from the input stream (e.g. stdin) and emits lines
-func (uci *UCI) lineReader(reader *bufio.Reader, in chan string) {
+// Passes the Evaluators option on to the engine and updates the check boxes
+// to match.
+func (uci *UCI) setEvaluators(value OptionValue) error {
+	if err := uci.Engine.SetOption(EVALUATORS, value); err != nil {
+		return err
+	}
+	names := splitEvaluatorNames(value.String)
+	for _, named := range NamedEvaluators {
+		enabled := false
+		for _, name := range names {
+			if strings.EqualFold(name, named.Name) {
+				enabled = true
+			}
+		}
+		uci.GetOption(named.Name).Value = BoolValue(enabled)
+	}
+	return nil
+}
 
+// Passes the evaluators that are checked on to the engine and updates the
+// Evaluators option to match.
+func (uci *UCI) setEvaluatorCheckBoxes(OptionValue) error {
+	names := []string{}
+	for _, named := range NamedEvaluators {
+		if uci.GetOption(named.Name).Value.Bool {
+			names = append(names, named.Name)
+		}
+	}
+	value := StringValue(strings.Join(names, ","))
+	if err := uci.Engine.SetOption(EVALUATORS, value); err != nil {
+		return err
+	}
+	uci.GetOption("Evaluators").Value = value
+	return nil
+}
+
+// Reads from the input stream (e.g. stdin) and emits lines. The channel is
+// closed when the input stream ends.
+func readLines(reader *bufio.Reader, in chan string) {
+	defer close(in)
 	for {
 		text, err := reader.ReadString('\n')
+		if text != "" {
+			in <- strings.TrimSpace(text)
+		}
 		if err != nil {
-			panic(err)
+			return
 		}
-		in <- strings.TrimSpace(text)
 	}
 }
 
 func (uci *UCI) Start(reader *bufio.Reader) {
-	log, err := os.Create(uci.LogFile)
-	if err != nil {
-		panic(err)
+	uci.running = true
+	defer func() {
+		uci.running = false
+		uci.closeLog()
+	}()
+	if err := uci.openLog(); err != nil {
+		uci.outputError(err)
 	}
-	defer log.Close()
 
 	input := make(chan string)
 	engineOutput := make(chan string, 50)
-	go uci.lineReader(reader, input)
+	go readLines(reader, input)
 
 	for {
 		select {
-		case cmdLine := <-input:
-			log.Write([]byte(cmdLine))
-			log.Write([]byte{'\n'})
+		case cmdLine, ok := <-input:
+			if !ok {
+				// The GUI has gone away
+				uci.stopSearch()
+				return
+			}
+			uci.writeLog("<<", cmdLine)
 			if cmdLine == "" {
 				continue
 			}
-			cmdParts := strings.Split(cmdLine, " ")
-			cmd := cmdParts[0]
-			switch cmd {
-			case "uci":
-				fmt.Println("id name " + uci.Name)
-				fmt.Println("id author " + uci.Author)
-				fmt.Println("uciok")
-				break
-			case "isready":
-				fmt.Println("readyok")
-				break
-			case "quit":
+			cmd, err := ParseUCICommand(cmdLine)
+			if err != nil {
+				uci.outputCommandError(uciCommandName(cmdLine), err)
+				continue
+			}
+			if cmd.Name == "quit" {
+				uci.stopSearch()
 				return
-			case "go":
-				if cmdParts[1] == "infinite" {
-					uci.Engine.Start(engineOutput, -1, -1)
-				} else if cmdParts[1] == "nodes" {
-					nodes, err := strconv.Atoi(cmdParts[2])
-					if err != nil {
-						panic(err)
-					}
-					uci.Engine.Start(engineOutput, nodes, -1)
-				} else if cmdParts[1] == "depth" {
-					depth, err := strconv.Atoi(cmdParts[2])
-					if err != nil {
-						panic(err)
-					}
-					uci.Engine.Start(engineOutput, -1, depth)
-				}
-				break
-			case "perft":
-				depth, err := strconv.Atoi(cmdParts[1])
-				if err != nil {
-					panic(err)
-				}
-				Perft(uci.Engine.GetPosition(), depth)
-			case "stop":
-				uci.Engine.Stop()
-				break
-			case "position":
-				if cmdParts[1] == "fen" {
-					fenStr := strings.Join(cmdParts[2:], " ")
-					fen, err := ParseFEN(fenStr)
-					if err != nil {
-						log.Write([]byte("Error parsing fen: " + err.Error()))
-						return
-					}
-					uci.Engine.SetPosition(fen)
-				} else if cmdParts[1] == "startpos" {
-					fenStr := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
-					fen, err := ParseFEN(fenStr)
-					if err != nil {
-						log.Write([]byte("Error parsing fen: " + err.Error()))
-						return
-					}
-					uci.Engine.SetPosition(fen)
-				}
+			}
+			if err := uci.execute(cmd, engineOutput); err != nil {
+				uci.outputCommandError(cmd.Name, err)
 			}
 		case out := <-engineOutput:
-			log.Write([]byte(">>> " + out + "\n"))
-			fmt.Println(out)
+			uci.output(out)
+			if strings.HasPrefix(out, "bestmove") {
+				uci.searching = false
+				uci.outputDebug("search took %dms", time.Since(uci.searchStarted).Milliseconds())
+			}
+		case result := <-uci.perftDone:
+			uci.searching = false
+			uci.cancelPerft()
+			uci.cancelPerft = nil
+			// A nil channel is never ready, so we stop listening to it
+			uci.perftDone = nil
+			uci.outputPerft(result)
+			uci.outputDebug("perft took %dms", time.Since(uci.searchStarted).Milliseconds())
 		}
 	}
 }
+
+func (uci *UCI) execute(cmd *UCICommand, engineOutput chan string) error {
+	switch cmd.Name {
+	case "uci":
+		uci.output("id name " + uci.Name)
+		uci.output("id author " + uci.Author)
+		for _, option := range uci.Options {
+			uci.output(option.String())
+		}
+		uci.output("uciok")
+	case "isready":
+		uci.output("readyok")
+	case "debug":
+		uci.Debug = cmd.Debug
+		uci.outputDebug("debug mode on")
+	case "ucinewgame":
+		if uci.searching {
+			return fmt.Errorf("Can't start a new game while searching")
+		}
+		uci.Engine.NewGame()
+		uci.outputDebug("cleared the search state for a new game")
+	case "setoption":
+		if uci.searching {
+			return fmt.Errorf("Can't set options while searching")
+		}
+		if err := uci.SetOption(cmd.OptionName, cmd.OptionValue); err != nil {
+			return err
+		}
+		uci.outputDebug("%s", uci.GetOption(cmd.OptionName).String())
+	case "go":
+		if uci.searching {
+			return fmt.Errorf("Already searching")
+		}
+		position := uci.Engine.GetPosition()
+		if position == nil {
+			return fmt.Errorf("No position set")
+		}
+		// We search the searchmoves we can play and skip the others. If
+		// there aren't any we leave them to the engine, which reports that
+		// there's nothing to search.
+		searchMoves := []*Move{}
+		for _, move := range cmd.Limits.SearchMoves {
+			valid := position.FindValidMove(move)
+			if valid == nil {
+				uci.outputError(fmt.Errorf("Invalid searchmove %s", move))
+				continue
+			}
+			searchMoves = append(searchMoves, valid)
+		}
+		if len(searchMoves) > 0 {
+			cmd.Limits.SearchMoves = searchMoves
+		}
+		uci.searching = true
+		uci.searchStarted = time.Now()
+		uci.outputDebug("searching %s with limits: %s", position.FENString(), cmd.Limits)
+		uci.Engine.Start(engineOutput, cmd.Limits)
+	case "perft":
+		if uci.searching {
+			return fmt.Errorf("Can't run perft while searching")
+		}
+		position := uci.Engine.GetPosition()
+		if position == nil {
+			return fmt.Errorf("No position set")
+		}
+		// Deep perfts can take a long time, so we count in the background
+		// like we search, to keep responding to isready, stop and quit.
+		ctx, cancel := context.WithCancel(context.Background())
+		uci.searching = true
+		uci.searchStarted = time.Now()
+		uci.cancelPerft = cancel
+		uci.perftDone = make(chan *perftResult, 1)
+		go func(done chan *perftResult) {
+			divisions, complete := PerftDivideContext(ctx, position, cmd.Depth, nil)
+			done <- &perftResult{divisions, complete}
+		}(uci.perftDone)
+	case "stop":
+		uci.stopSearch()
+	case "ponderhit":
+		if uci.searching && uci.cancelPerft == nil {
+			uci.Engine.PonderHit()
+		}
+	case "position":
+		if uci.searching {
+			return fmt.Errorf("Can't change the position while searching")
+		}
+		uci.Engine.SetPosition(cmd.Position)
+		uci.outputDebug("position %s", cmd.Position.FENString())
+	}
+	return nil
+}
+
+func (uci *UCI) stopSearch() {
+	if uci.cancelPerft != nil {
+		uci.cancelPerft()
+	} else if uci.searching {
+		uci.Engine.Stop()
+	}
+}
+
+// The counts for every move in the root position, and whether we counted
+// everything or were stopped early.
+type perftResult struct {
+	divisions []*PerftDivision
+	complete  bool
+}
+
+// Sends the counts with the same output as Stockfish's go perft, so the
+// numbers can be compared move by move, except for the blank line before the
+// total. The output always ends with the total, even when we were stopped.
+func (uci *UCI) outputPerft(result *perftResult) {
+	nodes := 0
+	for _, division := range result.divisions {
+		uci.output(fmt.Sprintf("%s: %d", division.Move, division.Nodes))
+		nodes += division.Nodes
+	}
+	if !result.complete {
+		uci.output("info string perft was stopped, so the count is incomplete")
+	}
+	uci.output(fmt.Sprintf("Nodes searched: %d", nodes))
+}
+
+func (uci *UCI) output(line string) {
+	uci.writeLog(">>", line)
+	fmt.Fprintln(uci.Output, line)
+}
+
+// Reports an error to the GUI in a way that doesn't break the protocol.
+func (uci *UCI) outputError(err error) {
+	uci.output("info string error: " + err.Error())
+}
+
+// Reports an error for a command we couldn't execute. The GUI waits for a
+// bestmove after every go, so we send one even when we can't search, unless
+// we're already searching and that search is going to send it.
+func (uci *UCI) outputCommandError(name string, err error) {
+	uci.outputError(err)
+	if name == "go" && !uci.searching {
+		uci.output("bestmove (none)")
+	}
+}
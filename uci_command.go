@@ -0,0 +1,153 @@
+package chess_engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A command from the GUI that has been parsed and validated, so that we
+// don't have to worry about malformed input when we execute it.
+type UCICommand struct {
+	Name string
+
+	// The position for the position command
+	Position *Game
+	// The search limits for the go command
+	Limits *SearchLimits
+	// The name and value for the setoption command
+	OptionName  string
+	OptionValue string
+	// The depth for the perft command
+	Depth int
+	// Whether to turn debug mode on or off
+	Debug bool
+}
+
+var uciCommands = map[string]bool{
+	"uci":        true,
+	"debug":      true,
+	"isready":    true,
+	"quit":       true,
+	"ucinewgame": true,
+	"setoption":  true,
+	"go":         true,
+	"perft":      true,
+	"stop":       true,
+	"ponderhit":  true,
+	"position":   true,
+}
+
+// Parses a line sent by the GUI. As required by the protocol, unknown tokens
+// in front of the command are skipped, so "joho isready" is the same as
+// "isready".
+func ParseUCICommand(line string) (*UCICommand, error) {
+	parts := skipUnknownTokens(line)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("Unknown command: %s", line)
+	}
+	cmd := &UCICommand{Name: parts[0]}
+	args := parts[1:]
+	switch cmd.Name {
+	case "debug":
+		if len(args) == 0 || (args[0] != "on" && args[0] != "off") {
+			return nil, fmt.Errorf("Expecting debug on or off")
+		}
+		cmd.Debug = args[0] == "on"
+	case "setoption":
+		cmd.OptionName, cmd.OptionValue = parseSetOption(args)
+		if cmd.OptionName == "" {
+			return nil, fmt.Errorf("Missing option name")
+		}
+	case "go":
+		limits, err := ParseSearchLimits(args)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Limits = limits
+	case "perft":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("Missing depth for perft")
+		}
+		depth, err := strconv.Atoi(args[0])
+		if err != nil || depth < 0 {
+			return nil, fmt.Errorf("Invalid depth for perft: %s", args[0])
+		}
+		cmd.Depth = depth
+	case "position":
+		game, err := parsePositionCommand(args)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Position = game
+	}
+	return cmd, nil
+}
+
+// Parses the arguments to setoption, e.g. "name Hash value 32" into the
+// option's name and value.
+func parseSetOption(args []string) (string, string) {
+	name, value := []string{}, []string{}
+	var current *[]string
+	for _, arg := range args {
+		if arg == "name" {
+			current = &name
+		} else if arg == "value" {
+			current = &value
+		} else if current != nil {
+			*current = append(*current, arg)
+		}
+	}
+	return strings.Join(name, " "), strings.Join(value, " ")
+}
+
+// Parses the arguments to the position command, e.g. "startpos moves e2e4
+// e7e5" or "fen <fen> moves e2e4".
+func parsePositionCommand(args []string) (*Game, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("Missing position")
+	}
+	movesIndex := len(args)
+	for i, arg := range args {
+		if arg == "moves" {
+			movesIndex = i
+			break
+		}
+	}
+	fenStr := ""
+	if args[0] == "startpos" {
+		fenStr = StartingPositionFEN
+	} else if args[0] == "fen" {
+		fenStr = strings.Join(args[1:movesIndex], " ")
+	} else {
+		return nil, fmt.Errorf("Unknown position %s", args[0])
+	}
+	game, err := ParseFEN(fenStr)
+	if err != nil {
+		return nil, err
+	}
+	if movesIndex == len(args) {
+		return game, nil
+	}
+	return game.ApplyMoves(args[movesIndex+1:])
+}
+
+// Returns the fields of @line starting at the command, or nothing if there
+// isn't a command in it.
+func skipUnknownTokens(line string) []string {
+	parts := strings.Fields(line)
+	for len(parts) > 0 && !uciCommands[parts[0]] {
+		parts = parts[1:]
+	}
+	return parts
+}
+
+// Returns the name of the command on @line, or "" if there isn't one. This
+// also works for lines that ParseUCICommand rejects.
+func uciCommandName(line string) string {
+	parts := skipUnknownTokens(line)
+	if len(parts) == 0 {
+		return ""
+	}
+	return parts[0]
+}
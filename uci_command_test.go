@@ -0,0 +1,73 @@
+package chess_engine
+
+import (
+	"testing"
+)
+
+func Test_ParseUCICommand(t *testing.T) {
+	cmd, err := ParseUCICommand("go wtime 1000 btime 2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "go" || cmd.Limits == nil || cmd.Limits.BlackTime.Milliseconds() != 2000 {
+		t.Errorf("Unexpected command %v", cmd)
+	}
+	cmd, err = ParseUCICommand("joho isready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "isready" {
+		t.Errorf("Expecting isready, got %s", cmd.Name)
+	}
+	cmd, err = ParseUCICommand("setoption name Hash value 32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.OptionName != "Hash" || cmd.OptionValue != "32" {
+		t.Errorf("Unexpected option %s %s", cmd.OptionName, cmd.OptionValue)
+	}
+	cmd, err = ParseUCICommand("position startpos moves e2e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Position == nil || cmd.Position.ToMove != Black {
+		t.Errorf("Expecting a position with black to move")
+	}
+	cmd, err = ParseUCICommand("perft 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Depth != 3 {
+		t.Errorf("Expecting depth 3, got %d", cmd.Depth)
+	}
+	cmd, err = ParseUCICommand("debug on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Debug {
+		t.Errorf("Expecting debug to be on")
+	}
+}
+
+func Test_ParseUCICommand_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"unknown command",
+		"go depth",
+		"go wtime abc",
+		"perft",
+		"perft -1",
+		"perft abc",
+		"position",
+		"position fen invalid",
+		"position startpos moves e2e5",
+		"setoption value 1",
+		"debug",
+		"debug maybe",
+	}
+	for _, testCase := range cases {
+		if _, err := ParseUCICommand(testCase); err == nil {
+			t.Errorf("Expecting an error for '%s'", testCase)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package chess_engine
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const DefaultLogFile = "/tmp/bsengine.log"
+
+// Opens the LogFile, closing the previous one if there was one. The file is
+// opened in append mode, so that multiple engines can share it. Logging is
+// disabled when the LogFile is empty.
+func (uci *UCI) openLog() error {
+	uci.closeLog()
+	if uci.LogFile == "" {
+		return nil
+	}
+	log, err := os.OpenFile(uci.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	uci.log = log
+	return nil
+}
+
+func (uci *UCI) closeLog() {
+	if uci.log != nil {
+		uci.log.Close()
+		uci.log = nil
+	}
+}
+
+// Changes the LogFile. If we're already running the new file is opened
+// straight away.
+func (uci *UCI) setLogFile(value OptionValue) error {
+	uci.LogFile = value.String
+	if uci.running {
+		return uci.openLog()
+	}
+	return nil
+}
+
+// Writes a line to the log, prefixed with a timestamp and a marker for the
+// direction: "<<" for lines we received from the GUI and ">>" for lines we
+// sent.
+func (uci *UCI) writeLog(direction, line string) {
+	if uci.log == nil {
+		return
+	}
+	fmt.Fprintf(uci.log, "%s %s %s\n", time.Now().Format("2006-01-02 15:04:05.000"), direction, line)
+}
+
+// Sends extra diagnostics to the GUI when debug mode is on.
+func (uci *UCI) outputDebug(format string, args ...interface{}) {
+	if uci.Debug {
+		uci.output("info string " + fmt.Sprintf(format, args...))
+	}
+}
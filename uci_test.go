@@ -0,0 +1,360 @@
+package chess_engine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parsePositionCommand(t *testing.T) {
+	cases := [][]string{
+		[]string{"startpos", StartingPositionFEN},
+		[]string{"startpos moves e2e4 e7e5", "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2"},
+		[]string{"fen 8/4P3/8/8/8/8/8/k6K w - - 0 1", "8/4P3/8/8/8/8/8/k6K w - - 0 1"},
+		[]string{"fen 8/4P3/8/8/8/8/8/k6K w - - 0 1 moves e7e8q a1b1", "4Q3/8/8/8/8/8/8/1k5K w - - 1 2"},
+	}
+	for _, testCase := range cases {
+		game, err := parsePositionCommand(strings.Split(testCase[0], " "))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.FENString() != testCase[1] {
+			t.Errorf("Expecting %s for '%s', got %s", testCase[1], testCase[0], game.FENString())
+		}
+	}
+}
+
+func Test_parsePositionCommand_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"unknown",
+		"fen invalid",
+		"startpos moves e2e5",
+		"startpos moves e2e4 e2e4",
+	}
+	for _, testCase := range cases {
+		if _, err := parsePositionCommand(strings.Fields(testCase)); err == nil {
+			t.Errorf("Expecting an error for '%s'", testCase)
+		}
+	}
+}
+
+func Test_UCI_SetOption(t *testing.T) {
+	engine := NewBSEngine(DefaultSelDepth)
+	unit := NewUCI("test", "test", engine)
+	if err := unit.SetOption("depth", "6"); err != nil {
+		t.Fatal(err)
+	}
+	if engine.SelDepth != 6 {
+		t.Errorf("Expecting SelDepth 6, got %d", engine.SelDepth)
+	}
+	if err := unit.SetOption("Hash", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.TranspositionTable.Entries) != len(NewTranspositionTable(1).Entries) {
+		t.Errorf("Expecting a 1MB transposition table")
+	}
+	if err := unit.SetOption("Unknown", "1"); err == nil {
+		t.Errorf("Expecting an error for an unknown option")
+	}
+}
+
+func Test_UCI_SetOption_evaluators(t *testing.T) {
+	engine := NewBSEngine(DefaultSelDepth)
+	unit := NewUCI("test", "test", engine)
+	if err := unit.SetOption("Evaluators", "naive-material, space"); err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.Evaluators) != 2 {
+		t.Errorf("Expecting 2 evaluators, got %d", len(engine.Evaluators))
+	}
+	if !unit.GetOption("naive-material").Value.Bool || !unit.GetOption("space").Value.Bool || unit.GetOption("tempo").Value.Bool {
+		t.Errorf("Expecting the check boxes to match the Evaluators option")
+	}
+
+	if err := unit.SetOption("space", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unit.SetOption("mobility", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.Evaluators) != 2 {
+		t.Errorf("Expecting 2 evaluators, got %d", len(engine.Evaluators))
+	}
+	if unit.GetOption("Evaluators").Value.String != "naive-material,mobility" {
+		t.Errorf("Expecting the Evaluators option to match the check boxes, got %s", unit.GetOption("Evaluators").Value.String)
+	}
+
+	if err := unit.SetOption("Evaluators", "unknown"); err == nil {
+		t.Errorf("Expecting an error for an unknown evaluator")
+	}
+	if unit.GetOption("Evaluators").Value.String != "naive-material,mobility" || len(engine.Evaluators) != 2 {
+		t.Errorf("Expecting the evaluators to be unchanged")
+	}
+}
+
+func Test_parseSetOption(t *testing.T) {
+	name, value := parseSetOption(strings.Split("name Evaluators value naive-material space", " "))
+	if name != "Evaluators" || value != "naive-material space" {
+		t.Errorf("Unexpected name '%s' or value '%s'", name, value)
+	}
+}
+
+// Feeds the input to a UCI loop and returns everything it output once the
+// input runs out.
+func runUCI(engine Engine, input string) string {
+	unit := NewUCI("test", "test", engine)
+	unit.LogFile = ""
+	output := &bytes.Buffer{}
+	unit.Output = output
+	unit.Start(bufio.NewReader(strings.NewReader(input)))
+	return output.String()
+}
+
+func Test_UCI_reports_errors(t *testing.T) {
+	engine := NewBSEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	input := []string{
+		"go",
+		"position fen invalid",
+		"position startpos moves e2e5",
+		"go depth abc",
+		"perft",
+		"setoption name Unknown value 1",
+		"setoption name Hash value 0",
+		"nonsense",
+		"isready",
+	}
+	output := runUCI(engine, strings.Join(input, "\n"))
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	// Every go that can't search still ends with a bestmove.
+	expected := []string{}
+	for _, cmd := range input[:len(input)-1] {
+		expected = append(expected, "info string error: ")
+		if strings.HasPrefix(cmd, "go") {
+			expected = append(expected, "bestmove (none)")
+		}
+	}
+	expected = append(expected, "readyok")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expecting %d lines of output, got %v", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, expected[i]) {
+			t.Errorf("Expecting '%s', got %s", expected[i], line)
+		}
+	}
+}
+
+func Test_UCI_doesnt_send_a_second_bestmove_while_searching(t *testing.T) {
+	engine := NewBSEngine(100)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	session := startUCI(engine)
+	defer session.close()
+	session.send("position startpos", "go infinite", "go depth abc", "go depth 1", "stop")
+	session.expect(t, "info string error: ")
+	session.expect(t, "info string error: Already searching")
+	if line := session.expect(t, "bestmove "); line == "bestmove (none)" {
+		t.Errorf("Expecting the bestmove from the search, got %s", line)
+	}
+	session.send("isready")
+	if line := session.expect(t, ""); line != "readyok" {
+		t.Errorf("Expecting readyok straight after the bestmove, got %s", line)
+	}
+}
+
+// A UCI loop running in the background, for commands that don't finish
+// before the next one is read. Commands are sent in the background too,
+// because the engine can't read the next command while we're not reading its
+// output.
+type uciSession struct {
+	input  chan string
+	output *bufio.Scanner
+}
+
+func startUCI(engine Engine) *uciSession {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	unit := NewUCI("test", "test", engine)
+	unit.LogFile = ""
+	unit.Output = outWriter
+	go func() {
+		unit.Start(bufio.NewReader(inReader))
+		outWriter.Close()
+	}()
+	input := make(chan string, 100)
+	go func() {
+		for line := range input {
+			fmt.Fprintln(inWriter, line)
+		}
+		inWriter.Close()
+	}()
+	return &uciSession{input, bufio.NewScanner(outReader)}
+}
+
+func (s *uciSession) send(lines ...string) {
+	for _, line := range lines {
+		s.input <- line
+	}
+}
+
+// Skips the output until a line starting with @prefix.
+func (s *uciSession) expect(t *testing.T, prefix string) string {
+	t.Helper()
+	for s.output.Scan() {
+		if line := s.output.Text(); strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	t.Fatalf("Expecting '%s', but the output ended", prefix)
+	return ""
+}
+
+func (s *uciSession) close() {
+	close(s.input)
+}
+
+func Test_UCI_perft(t *testing.T) {
+	session := startUCI(NewBSEngine(2))
+	defer session.close()
+	session.send("position startpos", "perft 2")
+	lines := []string{}
+	for i := 0; i < 21; i++ {
+		lines = append(lines, session.expect(t, ""))
+	}
+	for i, expected := range map[int]string{0: "a2a3: 20", 14: "g1f3: 20", 19: "h2h4: 20", 20: "Nodes searched: 400"} {
+		if lines[i] != expected {
+			t.Errorf("Expecting '%s' in the perft output, got %v", expected, lines)
+		}
+	}
+	// We're not searching anymore, so we can change the position
+	session.send("position startpos moves e2e4", "isready")
+	if line := session.expect(t, ""); line != "readyok" {
+		t.Errorf("Expecting readyok after the perft, got %s", line)
+	}
+}
+
+func Test_UCI_perft_can_be_stopped(t *testing.T) {
+	session := startUCI(NewBSEngine(2))
+	defer session.close()
+	session.send("position startpos", "perft 10", "isready")
+	session.expect(t, "readyok")
+	session.send("position startpos moves e2e4")
+	session.expect(t, "info string error: Can't change the position while searching")
+	session.send("stop")
+	session.expect(t, "info string perft was stopped")
+	session.expect(t, "Nodes searched: ")
+	session.send("perft 1")
+	session.expect(t, "Nodes searched: 20")
+}
+
+func Test_UCI_only_searches_once_at_a_time(t *testing.T) {
+	engine := NewBSEngine(100)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	output := runUCI(engine, "position startpos moves e2e4\ngo infinite\ngo infinite\n")
+	if !strings.Contains(output, "info string error: Already searching") {
+		t.Errorf("Expecting an error for the second go command, got %s", output)
+	}
+}
+
+// Whatever the GUI sends, we should still answer isready afterwards.
+func Test_UCI_stays_responsive(t *testing.T) {
+	lines := []string{
+		"uci",
+		"isready",
+		"ucinewgame",
+		"position startpos moves e2e4 e7e5 g1f3",
+		"position fen 8/4P3/8/8/8/8/8/k6K w - - 0 1 moves e7e8q",
+		"position fen",
+		"go depth 1",
+		"go infinite",
+		"go wtime 100 btime 100 winc 0 binc 0",
+		"go nodes",
+		"perft 1",
+		"setoption name Hash value 1",
+		"setoption name Evaluators value space,unknown",
+		"go ponder wtime 100 btime 100",
+		"ponderhit",
+		"stop",
+	}
+	for _, line := range lines {
+		engine := NewBSEngine(2)
+		engine.AddEvaluator(NaiveMaterialEvaluator)
+		output := runUCI(engine, "position startpos\n"+line+"\nisready\n")
+		if !strings.Contains(output, "readyok") {
+			t.Errorf("Expecting the engine to still be responsive after '%s'", line)
+		}
+	}
+}
+
+func Test_UCI_validates_searchmoves(t *testing.T) {
+	engine := NewBSEngine(2)
+	engine.AddEvaluator(NaiveMaterialEvaluator)
+	session := startUCI(engine)
+	defer session.close()
+
+	session.send("position startpos", "go depth 1 searchmoves e2e5 g1f3")
+	session.expect(t, "info string error: Invalid searchmove e2e5")
+	if line := session.expect(t, "bestmove "); line != "bestmove g1f3" {
+		t.Errorf("Expecting the search to continue with g1f3, got %s", line)
+	}
+
+	session.send("go depth 1 searchmoves e2e5")
+	session.expect(t, "info string error: Invalid searchmove e2e5")
+	session.expect(t, "info string error: None of the searchmoves can be played")
+	if line := session.expect(t, "bestmove "); line != "bestmove (none)" {
+		t.Errorf("Expecting bestmove (none) without moves to search, got %s", line)
+	}
+}
+
+func Test_UCI_appends_to_the_log_file(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chess_engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "engine.log")
+	for i := 0; i < 2; i++ {
+		unit := NewUCI("test", "test", NewRandomEngine())
+		unit.Output = &bytes.Buffer{}
+		if err := unit.SetOption("Debug Log File", logFile); err != nil {
+			t.Fatal(err)
+		}
+		unit.Start(bufio.NewReader(strings.NewReader("isready\n")))
+	}
+	contents, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expecting 4 lines in the log, got %d: %v", len(lines), lines)
+	}
+	for i, line := range lines {
+		expected := "<< isready"
+		if i%2 == 1 {
+			expected = ">> readyok"
+		}
+		if !strings.HasSuffix(line, expected) {
+			t.Errorf("Expecting '%s' to end with '%s'", line, expected)
+		}
+		if _, err := time.Parse("2006-01-02 15:04:05.000", line[:23]); err != nil {
+			t.Errorf("Expecting a timestamp in '%s': %s", line, err)
+		}
+	}
+}
+
+func Test_UCI_debug_mode(t *testing.T) {
+	output := runUCI(NewRandomEngine(), "position startpos\ndebug on\nposition startpos\ndebug off\nposition startpos\n")
+	expected := "info string debug mode on\ninfo string position " + StartingPositionFEN + "\n"
+	if output != expected {
+		t.Errorf("Expecting '%s', got '%s'", expected, output)
+	}
+}
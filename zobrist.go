@@ -0,0 +1,66 @@
+package chess_engine
+
+import (
+	"math/rand"
+)
+
+// Zobrist hashing gives every (piece, square) combination, the side to move,
+// every castling right and every en passant file a random 64 bit key. The
+// hash of a position is the XOR of all the keys that apply to it, which means
+// we can cheaply update it when applying a move by XOR'ing the keys that
+// changed.
+var (
+	zobristPieces      [12 * 64]uint64
+	zobristBlackToMove uint64
+	zobristCastling    [2][4]uint64
+	zobristEnPassant   [8]uint64
+)
+
+func init() {
+	// Use a fixed seed so that hashes are stable between runs.
+	r := rand.New(rand.NewSource(0x5ca1ab1e))
+	for i := range zobristPieces {
+		zobristPieces[i] = r.Uint64()
+	}
+	zobristBlackToMove = r.Uint64()
+	for _, color := range Colors {
+		for i := range zobristCastling[color] {
+			zobristCastling[color][i] = r.Uint64()
+		}
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = r.Uint64()
+	}
+}
+
+func zobristPiece(piece Piece, pos Position) uint64 {
+	return zobristPieces[int(piece)*64+int(pos)]
+}
+
+func zobristCastleStatuses(cs CastleStatuses) uint64 {
+	return zobristCastling[White][cs.White] ^ zobristCastling[Black][cs.Black]
+}
+
+func zobristEnPassantSquare(pos Position) uint64 {
+	if pos == NoPosition {
+		return 0
+	}
+	return zobristEnPassant[pos.GetFile()-FileA]
+}
+
+// Calculates the Zobrist hash for this Game from scratch. The move counters
+// are not part of the hash.
+func (f *Game) ComputeHash() uint64 {
+	hash := uint64(0)
+	for pos, piece := range f.Board {
+		if piece != NoPiece {
+			hash ^= zobristPiece(piece, Position(pos))
+		}
+	}
+	if f.ToMove == Black {
+		hash ^= zobristBlackToMove
+	}
+	hash ^= zobristCastleStatuses(f.CastleStatuses)
+	hash ^= zobristEnPassantSquare(f.EnPassantVulnerable)
+	return hash
+}
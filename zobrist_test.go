@@ -0,0 +1,64 @@
+package chess_engine
+
+import (
+	"testing"
+)
+
+func Test_Zobrist_transposition(t *testing.T) {
+	unit, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line1 := unit.ApplyMove(MustParseMove("g1f3")).ApplyMove(MustParseMove("g8f6")).ApplyMove(MustParseMove("b1c3"))
+	line2 := unit.ApplyMove(MustParseMove("b1c3")).ApplyMove(MustParseMove("g8f6")).ApplyMove(MustParseMove("g1f3"))
+	if line1.Hash != line2.Hash {
+		t.Errorf("Expecting transpositions to have the same hash")
+	}
+	if line1.Hash == unit.Hash {
+		t.Errorf("Expecting different positions to have different hashes")
+	}
+	blackToMove, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blackToMove.Hash == unit.Hash {
+		t.Errorf("Expecting the side to move to be part of the hash")
+	}
+	noCastling, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w Kkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noCastling.Hash == unit.Hash {
+		t.Errorf("Expecting the castling rights to be part of the hash")
+	}
+}
+
+func checkZobristHashes(t *testing.T, game *Game, depth int) {
+	if game.Hash != game.ComputeHash() {
+		t.Fatalf("Expecting incremental hash %x to equal computed hash %x after %s", game.Hash, game.ComputeHash(), Line(game.Line))
+	}
+	if depth == 0 {
+		return
+	}
+	for _, next := range game.NextGames() {
+		checkZobristHashes(t, next, depth-1)
+	}
+}
+
+func Test_Zobrist_incremental(t *testing.T) {
+	cases := []string{
+		// Castling
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		// En passant
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		// Promotions
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	}
+	for _, fen := range cases {
+		unit, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkZobristHashes(t, unit, 3)
+	}
+}